@@ -0,0 +1,229 @@
+"""Local diff analysis: per-file change stats computed without any API call."""
+
+from dataclasses import dataclass, field
+
+STATUS_ADDED = "added"
+STATUS_MODIFIED = "modified"
+STATUS_DELETED = "deleted"
+STATUS_RENAMED = "renamed"
+
+CATEGORY_CODE = "code"
+CATEGORY_DOCS = "docs"
+CATEGORY_TESTS = "tests"
+CATEGORY_CONFIG = "config"
+CATEGORY_CI = "ci"
+# Change types: a category every file shares, or mixed. "default" names the fallback template.
+CHANGE_TYPE_MIXED = "mixed"
+TEMPLATE_DEFAULT_KEY = "default"
+TEMPLATE_KEYS = (
+    CATEGORY_CODE,
+    CATEGORY_DOCS,
+    CATEGORY_TESTS,
+    CATEGORY_CONFIG,
+    CATEGORY_CI,
+    CHANGE_TYPE_MIXED,
+    TEMPLATE_DEFAULT_KEY,
+)
+
+_DOCS_EXTENSIONS = (".md", ".rst", ".txt", ".adoc")
+_CONFIG_EXTENSIONS = (".json", ".toml", ".yaml", ".yml", ".ini", ".cfg", ".lock", ".env")
+
+
+@dataclass
+class FileChange:
+    path: str
+    status: str = STATUS_MODIFIED
+    category: str = CATEGORY_CODE
+    lines_added: int = 0
+    lines_removed: int = 0
+
+
+@dataclass
+class DiffAnalysis:
+    files: list[FileChange] = field(default_factory=list)
+
+    def count_status(self, status: str) -> int:
+        return sum(1 for change in self.files if change.status == status)
+
+    def count_categories(self) -> dict[str, int]:
+        counts: dict[str, int] = {}
+        for change in self.files:
+            counts[change.category] = counts.get(change.category, 0) + 1
+        return counts
+
+    @property
+    def lines_added(self) -> int:
+        return sum(change.lines_added for change in self.files)
+
+    @property
+    def lines_removed(self) -> int:
+        return sum(change.lines_removed for change in self.files)
+
+
+def categorize_path(path: str) -> str:
+    """Map a repository path to a coarse category the model can reason about."""
+    if not isinstance(path, str) or not path:
+        raise ValueError("path must be a non-empty string")
+    lowered = path.lower()
+    name = lowered.rsplit("/", 1)[-1]
+    # Order matters: a test fixture under docs/ is still a test, a workflow is CI, not config.
+    if lowered.startswith(".github/") or lowered.startswith(".gitlab-ci"):
+        return CATEGORY_CI
+    if lowered.startswith("tests/") or "/tests/" in lowered or name.startswith("test_"):
+        return CATEGORY_TESTS
+    if lowered.startswith("docs/") or name.endswith(_DOCS_EXTENSIONS):
+        return CATEGORY_DOCS
+    if name.endswith(_CONFIG_EXTENSIONS) or name.startswith("."):
+        return CATEGORY_CONFIG
+    return CATEGORY_CODE
+
+
+def _path_from_header(line: str) -> str:
+    # "diff --git a/<path> b/<path>": take the b-side so renames report the new name.
+    _, _, b_side = line.partition(" b/")
+    return b_side.strip()
+
+
+def analyze_diff(diff: str) -> DiffAnalysis:
+    """Parse a unified git diff into per-file status, category and line counts."""
+    if not isinstance(diff, str):
+        raise TypeError(f"diff must be a string, got {type(diff).__name__}")
+
+    analysis = DiffAnalysis()
+    current: FileChange | None = None
+    in_hunk = False
+
+    for line in diff.splitlines():
+        if line.startswith("diff --git "):
+            current = FileChange(path=_path_from_header(line))
+            analysis.files.append(current)
+            in_hunk = False
+        elif current is None:
+            continue
+        elif line.startswith("@@"):
+            in_hunk = True
+        elif in_hunk and line.startswith("+"):
+            current.lines_added += 1
+        elif in_hunk and line.startswith("-"):
+            current.lines_removed += 1
+        elif line.startswith("new file mode"):
+            current.status = STATUS_ADDED
+        elif line.startswith("deleted file mode"):
+            current.status = STATUS_DELETED
+        elif line.startswith("rename to "):
+            current.status = STATUS_RENAMED
+            current.path = line[len("rename to ") :].strip()
+
+    for change in analysis.files:
+        change.category = categorize_path(change.path) if change.path else CATEGORY_CODE
+
+    return analysis
+
+
+def format_analysis(analysis: DiffAnalysis) -> str:
+    """Render the summary lines that are sent to the model and shown in previews."""
+    if not isinstance(analysis, DiffAnalysis):
+        raise TypeError(f"analysis must be a DiffAnalysis, got {type(analysis).__name__}")
+
+    categories = analysis.count_categories()
+    category_text = ", ".join(f"{name} {count}" for name, count in sorted(categories.items()))
+    lines = [
+        f"Files changed: {len(analysis.files)}"
+        f" ({analysis.count_status(STATUS_ADDED)} added,"
+        f" {analysis.count_status(STATUS_MODIFIED)} modified,"
+        f" {analysis.count_status(STATUS_DELETED)} deleted,"
+        f" {analysis.count_status(STATUS_RENAMED)} renamed)",
+        f"Lines: +{analysis.lines_added} -{analysis.lines_removed}",
+        f"Categories: {category_text or 'none'}",
+    ]
+    return "\n".join(lines)
+
+
+def wants_body(analysis: DiffAnalysis, files_min: int, lines_min: int) -> bool | None:
+    """Decide whether the change is big enough to explain; None leaves it to the prompt.
+
+    A threshold of 0 is ignored, and with both at 0 there is no decision at all.
+    """
+    if files_min < 0 or lines_min < 0:
+        raise ValueError(f"thresholds must be >= 0, got {files_min} and {lines_min}")
+    if files_min == 0 and lines_min == 0:
+        return None
+    lines_changed = analysis.lines_added + analysis.lines_removed
+    large_by_files = files_min > 0 and len(analysis.files) >= files_min
+    large_by_lines = lines_min > 0 and lines_changed >= lines_min
+    return large_by_files or large_by_lines
+
+
+def change_type(analysis: DiffAnalysis) -> str | None:
+    """Return the category all files share, "mixed" otherwise, or None for an empty diff."""
+    categories = analysis.count_categories()
+    if not categories:
+        return None
+    if len(categories) > 1:
+        return CHANGE_TYPE_MIXED
+    return next(iter(categories))
+
+
+def template_for_change(analysis: DiffAnalysis, templates: dict[str, str]) -> str:
+    """Pick the template for this change's type, falling back to "default", else none."""
+    kind = change_type(analysis)
+    if kind in templates:
+        return templates[kind]
+    return templates.get(TEMPLATE_DEFAULT_KEY, "")
+
+
+ROOT_AREA = "(root)"
+OTHER_AREA = "(other)"
+
+
+@dataclass
+class DiffGroup:
+    area: str
+    paths: list[str] = field(default_factory=list)
+    diff: str = ""
+
+
+def area_of(path: str) -> str:
+    """Return the top-level directory a path lives in, the unit --split groups by."""
+    if not isinstance(path, str) or not path:
+        raise ValueError("path must be a non-empty string")
+    head, separator, _ = path.partition("/")
+    return head if separator else ROOT_AREA
+
+
+def split_diff_by_file(diff: str) -> list[tuple[str, str]]:
+    """Cut a unified git diff into (path, per-file diff) pairs, in diff order."""
+    if not isinstance(diff, str):
+        raise TypeError(f"diff must be a string, got {type(diff).__name__}")
+
+    chunks: list[tuple[str, list[str]]] = []
+    for line in diff.splitlines():
+        if line.startswith("diff --git "):
+            chunks.append((_path_from_header(line), [line]))
+        elif chunks:
+            chunks[-1][1].append(line)
+    return [(path, "\n".join(lines)) for path, lines in chunks if path]
+
+
+def group_diff_by_area(diff: str, groups_max: int) -> list[DiffGroup]:
+    """Group per-file diffs by top-level area, folding the smallest areas past the cap."""
+    if groups_max < 1:
+        raise ValueError(f"groups_max must be at least 1, got {groups_max}")
+
+    groups: dict[str, DiffGroup] = {}
+    for path, file_diff in split_diff_by_file(diff):
+        area = area_of(path)
+        group = groups.setdefault(area, DiffGroup(area=area))
+        group.paths.append(path)
+        group.diff = f"{group.diff}\n{file_diff}" if group.diff else file_diff
+
+    # Largest areas keep their own group; each extra group is one more API request.
+    ordered = sorted(groups.values(), key=lambda group: len(group.paths), reverse=True)
+    if len(ordered) <= groups_max:
+        return ordered
+    kept = ordered[: groups_max - 1]
+    other = DiffGroup(area=OTHER_AREA)
+    for group in ordered[groups_max - 1 :]:
+        other.paths.extend(group.paths)
+        other.diff = f"{other.diff}\n{group.diff}" if other.diff else group.diff
+    return kept + [other]
@@ -1,12 +1,29 @@
 """Typer application entry point: registers commands and runs global setup."""
 
-from typing import Optional
+import os
+from typing import Mapping, Optional
 
 import typer
+from rich.console import Console
 
 from noidea import __version__
-from noidea.commands import init, keys_app, status, suggest, test, update
+from noidea.commands import (
+    commit,
+    config_app,
+    init,
+    keys_app,
+    models,
+    pr_description,
+    review,
+    serve,
+    status,
+    suggest,
+    test,
+    update,
+    validate,
+)
 from noidea.config import initialize
+from noidea.provider import log_requests
 
 app = typer.Typer(
     name="noidea",
@@ -14,13 +31,51 @@ app = typer.Typer(
     no_args_is_help=True,
     help="You have no idea what to write in your commits? We got you.",
 )
+app.add_typer(config_app, name="config")
 app.add_typer(keys_app, name="keys")
 
+app.command()(commit.commit)
 app.command()(init.init)
+app.command()(models.models)
+app.command()(pr_description.pr_description)
+app.command()(review.review)
+app.command()(serve.serve)
 app.command()(status.status)
 app.command()(suggest.suggest)
 app.command()(test.test)
 app.command()(update.update)
+app.command()(validate.validate)
+
+
+# Commands that print through their own rich console; --no-color/--force-color rebuild them.
+_COLOR_MODULES = (pr_description, review, status, suggest, test)
+
+
+def color_setting(no_color: bool, force_color: bool, environ: Mapping[str, str]) -> bool | None:
+    """True forces colour, False turns it off, None leaves it to rich and the terminal."""
+    # Flags beat the environment, and an explicit --no-color beats everything.
+    if no_color:
+        return False
+    if force_color:
+        return True
+    if environ.get("NO_COLOR"):
+        return False
+    # Set but empty reads as unset, as it does for NO_COLOR; only "0" is an explicit no.
+    if environ.get("CLICOLOR_FORCE", "0") not in ("", "0"):
+        return True
+    return None
+
+
+def apply_color(setting: bool | None) -> None:
+    if setting is None:
+        return
+    for module in _COLOR_MODULES:
+        # force_terminal, because a pipe is exactly where rich would otherwise drop colour.
+        module.console = Console(
+            stderr=module.console.stderr,
+            force_terminal=True if setting else None,
+            no_color=not setting,
+        )
 
 
 def version_callback(value: bool):
@@ -38,7 +93,20 @@ def main(
         callback=version_callback,
         is_eager=True,
     ),
+    no_color: bool = typer.Option(False, "--no-color", help="Never print colour"),
+    force_color: bool = typer.Option(
+        False, "--force-color", help="Print colour even into a pipe (also CLICOLOR_FORCE=1)"
+    ),
+    request_log: Optional[str] = typer.Option(
+        None,
+        "--log-requests",
+        metavar="FILE",
+        help="Append each API request and response to FILE as JSON, secrets redacted",
+    ),
 ) -> None:
+    apply_color(color_setting(no_color, force_color, os.environ))
+    if request_log:
+        log_requests(request_log)
     initialize()
 
 
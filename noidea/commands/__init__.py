@@ -1,6 +1,37 @@
 """Re-exports command modules for CLI registration."""
 
-from noidea.commands import init, keys, status, suggest, test, update
+from noidea.commands import (
+    commit,
+    config,
+    init,
+    keys,
+    models,
+    pr_description,
+    review,
+    serve,
+    status,
+    suggest,
+    test,
+    update,
+    validate,
+)
+from noidea.commands.config import config_app
 from noidea.commands.keys import keys_app
 
-__all__ = ["init", "keys", "keys_app", "status", "suggest", "test", "update"]
+__all__ = [
+    "commit",
+    "config",
+    "config_app",
+    "init",
+    "keys",
+    "keys_app",
+    "models",
+    "pr_description",
+    "review",
+    "serve",
+    "status",
+    "suggest",
+    "test",
+    "update",
+    "validate",
+]
@@ -0,0 +1,42 @@
+import typer
+
+from noidea.commands.suggest import generate_commit_message, resolve_sign_off
+from noidea.git import commit_with_message, get_diff
+
+
+def commit(
+    model: str = typer.Option(None, "--model", "-M", help="Run generation with a different model"),
+    yes: bool = typer.Option(
+        False, "--yes", "-y", help="Send the diff and commit without asking for confirmation"
+    ),
+    sign_off: bool = typer.Option(
+        False, "--sign-off", "-s", help="Append a Signed-off-by trailer from git config"
+    ),
+):
+    """Trust the machine. Generates a message and commits with it in one go."""
+    diff = get_diff()
+    if not diff.has_changes or not diff.diff.strip():
+        print("Nothing staged yet. Stage some changes first — there's nothing to commit.")
+        raise typer.Exit(1)
+
+    # Resolve before the API call so a missing identity doesn't waste a request.
+    sign_off_trailer = resolve_sign_off() if sign_off else None
+    if sign_off and sign_off_trailer is None:
+        raise typer.Exit(1)
+
+    trailers = [sign_off_trailer] if sign_off_trailer else []
+    commit_message = generate_commit_message(diff.diff, model, assume_yes=yes, trailers=trailers)
+    if commit_message is None or not commit_message.strip():
+        print("No commit message was generated, so nothing was committed.")
+        raise typer.Exit(1)
+
+    print(commit_message)
+    if not yes and not typer.confirm("\nCommit with this message?", default=True):
+        print("Aborted. Your changes are still staged.")
+        raise typer.Exit(1)
+
+    result = commit_with_message(commit_message)
+    if not result.success:
+        print(f"Commit failed: {result.error}")
+        raise typer.Exit(1)
+    print(result.output)
@@ -0,0 +1,114 @@
+import json
+import os
+
+import keyring
+import keyring.errors
+import typer
+
+from noidea.config import (
+    CONFIG_PATH,
+    SERVICE_NAME,
+    forget_key_date,
+    get_repo_config_path,
+    list_keys,
+    parse_dotted_key,
+    remove_key,
+    reset_config,
+    reset_config_keys,
+)
+
+config_app = typer.Typer(help="Manage your config. For when hand-editing JSON went sideways.")
+
+
+def _parse_keys(keys: str) -> list[str]:
+    """Split --keys and check every name before anything is touched."""
+    dotted_keys = [key.strip() for key in keys.split(",") if key.strip()]
+    try:
+        if not dotted_keys:
+            raise ValueError("--keys needs at least one key, e.g. llm.max_tokens")
+        for dotted_key in dotted_keys:
+            parse_dotted_key(dotted_key)
+    except ValueError as error:
+        print(str(error))
+        raise typer.Exit(1)
+    return dotted_keys
+
+
+def _target_path(repo: bool) -> str | None:
+    if not repo:
+        return CONFIG_PATH
+    path = get_repo_config_path()
+    if path is None:
+        print("Not inside a git repository, so there's no repo config.")
+    return path
+
+
+def _delete_stored_keys() -> None:
+    """Remove every API key noidea stored, from the keyring and from its own records."""
+    for name in list_keys():
+        try:
+            keyring.delete_password(service_name=SERVICE_NAME, username=name)
+        except keyring.errors.PasswordDeleteError:
+            # Already gone from the keyring; still drop noidea's record of it.
+            pass
+        remove_key(name)
+        forget_key_date(name)
+        print(f"Deleted stored key: {name}")
+
+
+def _reset_file(path: str, dotted_keys: list[str] | None, repo: bool) -> None:
+    if dotted_keys is not None:
+        removed = reset_config_keys(path, dotted_keys)
+        for dotted_key in dotted_keys:
+            state = "reset to default" if dotted_key in removed else "was not set"
+            print(f"{dotted_key}: {state}")
+    elif repo:
+        # The repo config is optional; without it the user config applies again.
+        os.remove(path)
+        print(f"Removed {path}.")
+    else:
+        reset_config(path)
+        print(f"Reset {path} to the defaults.")
+
+
+@config_app.command()
+def reset(
+    keys: str = typer.Option(
+        None, "--keys", help="Comma-separated keys to reset, e.g. llm.max_tokens,llm.trailers"
+    ),
+    reset_all: bool = typer.Option(False, "--all", help="Reset the whole config file"),
+    repo: bool = typer.Option(False, "--repo", help="Reset this repo's config, not yours"),
+    include_keys: bool = typer.Option(
+        False, "--include-keys", help="Also delete stored API keys from the keyring"
+    ),
+    yes: bool = typer.Option(False, "--yes", "-y", help="Don't ask for confirmation"),
+):
+    """Put config back to the defaults, key by key or all at once."""
+    if (keys is None) == (not reset_all):
+        print("Pass exactly one of --keys or --all.")
+        raise typer.Exit(1)
+    dotted_keys = _parse_keys(keys) if keys is not None else None
+    path = _target_path(repo)
+    if path is None:
+        raise typer.Exit(1)
+    if not os.path.exists(path):
+        print(f"No config at {path}. Nothing to reset.")
+        raise typer.Exit(1)
+
+    what = ", ".join(dotted_keys) if dotted_keys else "everything"
+    if include_keys:
+        what += " and every stored API key"
+    if not yes and not typer.confirm(f"Reset {what} in {path}?", default=False):
+        print("Nothing was reset.")
+        return
+
+    try:
+        _reset_file(path, dotted_keys, repo)
+        if include_keys:
+            _delete_stored_keys()
+    except (OSError, json.JSONDecodeError) as error:
+        print(f"Couldn't reset: {error}")
+        raise typer.Exit(1)
+    except keyring.errors.KeyringError as error:
+        print(f"Couldn't delete a key from the keyring: {error}")
+        raise typer.Exit(1)
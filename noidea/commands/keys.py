@@ -4,7 +4,17 @@ import keyring
 import keyring.errors
 import typer
 
-from noidea.config import SERVICE_NAME, Provider, list_keys, remove_key, save_key
+from noidea.config import (
+    SERVICE_NAME,
+    Provider,
+    forget_key_date,
+    get_key_age_days,
+    list_keys,
+    load_config,
+    record_key_date,
+    remove_key,
+    save_key,
+)
 
 keys_app = typer.Typer(help="Manage your API keys. The one secret you actually need to keep.")
 
@@ -28,10 +38,12 @@ def add(provider: Provider = typer.Argument(default=Provider.ANTHROPIC)):
     try:
         key = typer.prompt("Enter your key:", hide_input=True)
         keyring.set_password(service_name=SERVICE_NAME, username=provider.value, password=key)
+        # The keyring entry was just overwritten, so its age restarts whether or not it is new.
+        record_key_date(provider.value)
         if save_key(provider.value):
             print("Key saved. You're ready to have no idea what to commit.")
         else:
-            print("You already have a key saved for this provider.")
+            print("Key replaced. The old one is gone from your keyring.")
     except keyring.errors.KeyringError as e:
         print(f"Couldn't save the key to keyring: {e}")
     except (OSError, json.JSONDecodeError) as e:
@@ -44,6 +56,7 @@ def remove(provider: Provider = typer.Argument(...)):
     try:
         keyring.delete_password(service_name=SERVICE_NAME, username=provider.value)
         if remove_key(provider.value):
+            forget_key_date(provider.value)
             print("Key removed. Gone, like your commit message inspiration.")
         else:
             print("Key not found. Nothing to remove.")
@@ -51,3 +64,30 @@ def remove(provider: Provider = typer.Argument(...)):
         print(f"Couldn't remove the key from keyring: {e}")
     except (OSError, json.JSONDecodeError) as e:
         print(f"Couldn't remove the key: {e}")
+
+
+def _format_key_age(name: str, rotation_days: int) -> str:
+    """Describe a key's age, with a rotation hint once it passes the configured threshold."""
+    age_days = get_key_age_days(name)
+    if age_days is None:
+        return f"{name}: added before age tracking, age unknown"
+    text = f"{name}: {age_days} days old"
+    # rotation_days of 0 means the user turned the reminder off.
+    if rotation_days > 0 and age_days >= rotation_days:
+        text += " — might be worth rotating"
+    return text
+
+
+@keys_app.command()
+def age():
+    """Show how long ago each API key was added."""
+    try:
+        keys = list_keys()
+        if not keys:
+            print("No keys found. Run 'noidea keys add' to get started.")
+        rotation_days = load_config()["llm"]["key_rotation_days"]
+        for key in keys:
+            print(_format_key_age(key, rotation_days))
+    except (OSError, ValueError) as e:
+        # ValueError covers both JSONDecodeError and a malformed stored date.
+        print(f"Couldn't read key ages: {e}")
@@ -0,0 +1,61 @@
+import typer
+
+from noidea.config import (
+    Provider,
+    active_provider,
+    load_config,
+    read_models_cache,
+    write_models_cache,
+)
+from noidea.provider import list_models, reported_api_errors
+
+
+def _fetch_models(refresh: bool, base_url: str) -> list[str] | None:
+    """Return model IDs from the cache when fresh, else from the API; "" is the public API."""
+    if not refresh:
+        try:
+            cached = read_models_cache(base_url)
+            if cached:
+                return cached
+        except (OSError, ValueError, KeyError):
+            # A broken cache is only a missed shortcut: fall through to the API.
+            pass
+
+    models = None
+    with reported_api_errors():
+        models = list_models(base_url or None)
+    if models is None:
+        return None
+
+    if models:
+        try:
+            write_models_cache(models, base_url)
+        except OSError:
+            # The listing already succeeded; failing to cache it only costs a later round-trip.
+            pass
+    return models
+
+
+def models(
+    refresh: bool = typer.Option(False, "--refresh", help="Ignore the cache and ask the API"),
+):
+    """See which models you can pick. No more guessing model names."""
+    config = load_config()
+    # A local server has no model listing in its Anthropic-compatible API; say so rather than
+    # list Anthropic models it can't run.
+    if active_provider(config) == Provider.OLLAMA:
+        print("noidea models lists Anthropic models. Run 'ollama list' to see your local ones.")
+        raise typer.Exit(1)
+    llm = config["llm"]
+    # Listing asks Anthropic, through the configured proxy if there is one.
+    model_ids = _fetch_models(refresh, llm["base_url"])
+    if model_ids is None:
+        raise typer.Exit(1)
+    if not model_ids:
+        print("The API returned no models for this key.")
+        return
+
+    roles = {llm["small_model"]: "small_model", llm["large_model"]: "large_model"}
+    for model_id in model_ids:
+        role = roles.get(model_id)
+        print(f"{model_id}  ({role})" if role else model_id)
@@ -0,0 +1,94 @@
+import typer
+from rich.console import Console
+
+from noidea.analysis import analyze_diff, format_analysis
+from noidea.commands.suggest import prepare_upload, select_model, with_model_override
+from noidea.config import active_provider, load_config, provider_base_url
+from noidea.git import (
+    DiffResult,
+    LogResult,
+    get_branch_name,
+    get_commit_messages,
+    get_range_diff,
+)
+from noidea.provider import get_commit_message, reported_api_errors
+
+console = Console(stderr=True)
+
+# A release branch can differ from main by megabytes; the model gets the head of the diff plus
+# the commit list and per-file summary, which still describe the whole range.
+PR_DIFF_CHARS_MAX = 200_000
+DIFF_CLIPPED_NOTE = "(diff clipped: the rest is covered by the commits and change summary)"
+
+
+def _read_range(rev_range: str, commits_max: int) -> tuple[LogResult, DiffResult] | None:
+    """Return the range's commits and combined diff, or None after telling the user why not."""
+    try:
+        diff = get_range_diff(rev_range)
+    except ValueError as error:
+        print(str(error))
+        return None
+    log = get_commit_messages(rev_range, commits_max)
+    if not log.success or diff.error:
+        print(f"Could not read {rev_range}: {log.error or diff.error}")
+        return None
+    if not log.commits or not diff.has_changes:
+        print(f"No changes in {rev_range}. Nothing to describe.")
+        return None
+    if log.truncated:
+        of_total = f" of {log.total}" if log.total else ""
+        print(f"Only using the newest {len(log.commits)}{of_total} commits in {rev_range}.")
+    return log, diff
+
+
+def _describe(config: dict, model: str, content: str) -> str | None:
+    """Call the API and return the description, or None on handled error."""
+    with reported_api_errors():
+        with console.status("[grey]Reading the whole branch...", spinner="dots"):
+            return get_commit_message(
+                content,
+                config["llm"]["pr_description_prompt"],
+                model,
+                config["llm"]["max_tokens"],
+                temperature=config["llm"]["temperature"],
+                provider=active_provider(config),
+                base_url=provider_base_url(config),
+                request_log=config["llm"]["request_log"],
+            )
+    return None
+
+
+def pr_description(
+    rev_range: str = typer.Option(..., "--range", help="Commits to describe, e.g. main..HEAD"),
+    model: str = typer.Option(None, "--model", "-M", help="Run with a different model"),
+    yes: bool = typer.Option(
+        False, "--yes", "-y", help="Send the diff without asking (llm.confirm_code_upload)"
+    ),
+):
+    """Write a pull request description from a branch's commits and diff."""
+    config = load_config()
+    change = _read_range(rev_range, config["llm"]["range_commits_max"])
+    if change is None:
+        raise typer.Exit(1)
+    log, diff = change
+
+    analysis = analyze_diff(diff.diff)
+    paths = [file_change.path for file_change in analysis.files]
+    diff_text = prepare_upload(config, diff.diff, get_branch_name(), paths, yes)
+    if diff_text is None:
+        raise typer.Exit(1)
+    if len(diff_text) > PR_DIFF_CHARS_MAX:
+        diff_text = diff_text[:PR_DIFF_CHARS_MAX] + f"\n{DIFF_CLIPPED_NOTE}"
+
+    # Oldest first, the order a reviewer reads the branch in.
+    commits = "\n\n".join(commit.message for commit in reversed(log.commits))
+    content = (
+        f"Change summary:\n{format_analysis(analysis)}\n\n"
+        f"Commits:\n{commits}\n\nDiff:\n{diff_text}"
+    )
+    config = with_model_override(config, model)
+    prompt_chars = len(config["llm"]["pr_description_prompt"])
+    description = _describe(config, select_model(config, prompt_chars + len(content)), content)
+    if description is None:
+        raise typer.Exit(1)
+    print(description)
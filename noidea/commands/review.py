@@ -0,0 +1,78 @@
+import typer
+from rich.console import Console
+
+from noidea.analysis import analyze_diff
+from noidea.commands.suggest import prepare_upload, select_model, with_model_override
+from noidea.config import active_provider, load_config, provider_base_url
+from noidea.git import get_branch_name, get_commit_diff, get_commit_messages
+from noidea.provider import get_commit_message, reported_api_errors
+
+console = Console(stderr=True)
+
+# One commit rarely needs more; past this the review would be shallow anyway.
+REVIEW_DIFF_CHARS_MAX = 100_000
+REVIEW_CLIPPED_NOTE = "(diff clipped: review only what is shown)"
+
+
+def _read_commit(revision: str) -> tuple[str, str] | None:
+    """Return (message, diff) of one commit, or None after telling the user why not."""
+    # rev^! is exactly that commit, with or without parents.
+    log = get_commit_messages(f"{revision}^!")
+    if not log.success or not log.commits:
+        print(f"Could not read commit {revision}: {log.error or 'no such commit'}")
+        return None
+    diff = get_commit_diff(revision)
+    if diff.error:
+        print(f"Could not read commit {revision}: {diff.error}")
+        return None
+    if not diff.has_changes:
+        print(f"Commit {revision} changes no files. Nothing to review.")
+        return None
+    return log.commits[0].message, diff.diff
+
+
+def _review(config: dict, model: str, content: str) -> str | None:
+    """Call the API and return the review, or None on handled error."""
+    with reported_api_errors():
+        with console.status("[grey]Reading it like a reviewer would...", spinner="dots"):
+            return get_commit_message(
+                content,
+                config["llm"]["review_prompt"],
+                model,
+                config["llm"]["max_tokens"],
+                temperature=config["llm"]["temperature"],
+                provider=active_provider(config),
+                base_url=provider_base_url(config),
+                request_log=config["llm"]["request_log"],
+            )
+    return None
+
+
+def review(
+    revision: str = typer.Argument("HEAD", help="Commit to review"),
+    model: str = typer.Option(None, "--model", "-M", help="Run with a different model"),
+    yes: bool = typer.Option(
+        False, "--yes", "-y", help="Send the diff without asking (llm.confirm_code_upload)"
+    ),
+):
+    """Get code review points on one commit, before someone else finds them."""
+    commit = _read_commit(revision)
+    if commit is None:
+        raise typer.Exit(1)
+    message, diff_text = commit
+
+    config = load_config()
+    paths = [change.path for change in analyze_diff(diff_text).files]
+    diff_text = prepare_upload(config, diff_text, get_branch_name(), paths, yes)
+    if diff_text is None:
+        raise typer.Exit(1)
+    if len(diff_text) > REVIEW_DIFF_CHARS_MAX:
+        diff_text = diff_text[:REVIEW_DIFF_CHARS_MAX] + f"\n{REVIEW_CLIPPED_NOTE}"
+
+    content = f"Commit message:\n{message}\n\nDiff:\n{diff_text}"
+    config = with_model_override(config, model)
+    prompt_chars = len(config["llm"]["review_prompt"])
+    text = _review(config, select_model(config, prompt_chars + len(content)), content)
+    if text is None:
+        raise typer.Exit(1)
+    print(text)
@@ -0,0 +1,130 @@
+import io
+import json
+import sys
+from contextlib import redirect_stdout
+
+import anthropic
+import keyring.errors
+import typer
+
+from noidea.analysis import analyze_diff
+from noidea.commands.suggest import (
+    CANDIDATES_COUNT_MAX,
+    CHOSEN_CANDIDATE_INDEX,
+    generate_commit_candidates,
+)
+from noidea.config import load_config
+from noidea.message_check import check_message
+from noidea.provider import reuse_clients
+
+# One request per line. A diff past this is rejected rather than held in memory and sent.
+REQUEST_CHARS_MAX = 10_000_000
+
+
+def _handle_suggest(request: dict) -> dict:
+    diff = request.get("diff")
+    if not isinstance(diff, str) or not diff.strip():
+        raise ValueError("'diff' must be a non-empty string")
+    count = request.get("count", 1)
+    if not isinstance(count, int) or not 1 <= count <= CANDIDATES_COUNT_MAX:
+        raise ValueError(f"'count' must be an integer from 1 to {CANDIDATES_COUNT_MAX}")
+    model = request.get("model")
+    if model is not None and not isinstance(model, str):
+        raise ValueError("'model' must be a string")
+
+    # The editor sends the diff it wants described, which may not be what git has staged.
+    paths = [change.path for change in analyze_diff(diff).files]
+    # Stdout carries the protocol, so the CLI's own messages are captured instead.
+    captured = io.StringIO()
+    try:
+        with redirect_stdout(captured):
+            candidates = generate_commit_candidates(
+                diff,
+                model,
+                assume_yes=request.get("yes") is True,
+                staged_files=paths,
+                count=count,
+            )
+    # get_api_key exits the CLI when no key is stored; a server should answer and carry on.
+    except SystemExit as exit_error:
+        raise ValueError(str(exit_error.code)) from None
+    if not candidates:
+        raise ValueError(captured.getvalue().strip() or "no message was generated")
+    # Same shape as 'suggest --json', so a plugin can parse both with one code path.
+    return {"candidates": candidates, "chosen": CHOSEN_CANDIDATE_INDEX}
+
+
+def _handle_validate(request: dict) -> dict:
+    message = request.get("message")
+    if not isinstance(message, str):
+        raise ValueError("'message' must be a string")
+    # Read per request, so editing the message rules applies without restarting the server.
+    llm = load_config()["llm"]
+    problems = check_message(
+        message, llm["allowed_scopes"], llm["message_pattern"], llm["message_pattern_example"]
+    )
+    return {"problems": problems}
+
+
+_HANDLERS = {"suggest": _handle_suggest, "validate": _handle_validate}
+
+
+def handle_request(line: str) -> dict:
+    """Answer one request line; every failure becomes an error response, never an exception."""
+    if len(line) > REQUEST_CHARS_MAX:
+        return {"id": None, "error": f"request is over {REQUEST_CHARS_MAX} characters"}
+    try:
+        request = json.loads(line)
+    except json.JSONDecodeError as error:
+        return {"id": None, "error": f"invalid JSON: {error}"}
+    if not isinstance(request, dict):
+        return {"id": None, "error": "request must be a JSON object"}
+
+    request_id = request.get("id")
+    method = request.get("method")
+    handler = _HANDLERS.get(method) if isinstance(method, str) else None
+    if handler is None:
+        methods = ", ".join(_HANDLERS)
+        return {"id": request_id, "error": f"unknown method {method!r} (use {methods})"}
+    try:
+        return {"id": request_id, "result": handler(request)}
+    # The keyring and API errors the CLI leaves to the user's terminal would end the server.
+    except (ValueError, TypeError, keyring.errors.KeyringError, anthropic.APIError) as error:
+        return {"id": request_id, "error": str(error) or type(error).__name__}
+
+
+def _read_request_line() -> str:
+    """Read one line, holding at most one char past the limit; "" means stdin is closed."""
+    line = sys.stdin.readline(REQUEST_CHARS_MAX + 1)
+    if len(line) <= REQUEST_CHARS_MAX or line.endswith("\n"):
+        return line
+    # Discard the rest of an oversized request, so its tail isn't read as the next one.
+    while True:
+        rest = sys.stdin.readline(REQUEST_CHARS_MAX + 1)
+        if not rest or rest.endswith("\n"):
+            return line
+
+
+def serve(
+    stdio: bool = typer.Option(
+        False, "--stdio", help="Read JSON requests from stdin, one per line; answer on stdout"
+    ),
+):
+    """Stay running for an editor plugin, answering suggest and validate requests."""
+    if not stdio:
+        print("Pass --stdio; it is the only transport for now.")
+        raise typer.Exit(1)
+
+    # One process serves many requests, so keep the API connection open between them.
+    reuse_clients()
+    out = sys.stdout
+    # readline, not iteration: it answers each request as soon as its line arrives. The loop
+    # runs until the editor closes stdin.
+    while True:
+        line = _read_request_line()
+        if not line:
+            break
+        if not line.strip():
+            continue
+        out.write(json.dumps(handle_request(line)) + "\n")
+        out.flush()
@@ -3,11 +3,32 @@ import os
 
 import keyring
 import keyring.errors
+import typer
 from rich.console import Console
 
 from noidea import __version__
-from noidea.config import CONFIG_PATH, SERVICE_NAME, list_keys, load_config
-from noidea.git import HOOK_NAME, get_git_root, get_hooks_dir
+from noidea.config import (
+    CONFIG_PATH,
+    SERVICE_NAME,
+    Provider,
+    get_key_age_days,
+    list_keys,
+    load_config,
+    record_key_date,
+    save_key,
+)
+from noidea.git import (
+    HOOK_NAME,
+    HOOK_STATE_CURRENT,
+    HOOK_STATE_FOREIGN,
+    HOOK_STATE_MISSING,
+    HOOK_STATE_STALE,
+    get_git_root,
+    get_hook_state,
+    get_hooks_dir,
+    install_hook,
+)
+from noidea.provider import API_KEY_ENV_VAR
 
 console = Console(stderr=True)
 
@@ -29,25 +50,26 @@ def _check_hook():
         console.print(f"Hook:           {FAIL} {HOOK_NAME} not found")
         return
 
-    hook_path = os.path.join(hooks_dir, HOOK_NAME)
-    if not os.path.exists(hook_path):
-        console.print(f"Hook:           {FAIL} {HOOK_NAME} not found")
-        return
-
     try:
-        with open(hook_path) as f:
-            content = f.read()
+        state = get_hook_state(hooks_dir)
     except OSError:
         console.print(f"Hook:           {FAIL} could not read {HOOK_NAME}")
         return
 
-    if SERVICE_NAME in content:
+    if state == HOOK_STATE_CURRENT:
         console.print(f"Hook:           {OK} {HOOK_NAME} installed ({hooks_dir})")
-    else:
+    elif state == HOOK_STATE_STALE:
+        console.print(
+            f"Hook:           [yellow]![/yellow] {HOOK_NAME} is from an older {SERVICE_NAME}"
+            " (run 'noidea status --fix')"
+        )
+    elif state == HOOK_STATE_FOREIGN:
         console.print(
             f"Hook:           [yellow]![/yellow] {HOOK_NAME} exists"
             f" but not managed by {SERVICE_NAME}"
         )
+    else:
+        console.print(f"Hook:           {FAIL} {HOOK_NAME} not found")
 
 
 def _check_config() -> tuple[dict, dict]:
@@ -60,13 +82,20 @@ def _check_config() -> tuple[dict, dict]:
     return config, llm
 
 
-def _check_api_keys():
+def _check_api_keys(rotation_days: int):
     try:
         keys = list_keys()
         if keys:
             for key in keys:
                 stored = keyring.get_password(SERVICE_NAME, key)
-                if stored:
+                age_days = get_key_age_days(key)
+                # rotation_days of 0 means the user turned the reminder off.
+                if stored and rotation_days > 0 and age_days and age_days >= rotation_days:
+                    console.print(
+                        f"API Key:        [yellow]![/yellow] {key} (keyring, {age_days} days old"
+                        " — might be worth rotating)"
+                    )
+                elif stored:
                     console.print(f"API Key:        {OK} {key} (keyring)")
                 else:
                     console.print(
@@ -74,19 +103,98 @@ def _check_api_keys():
                     )
         else:
             console.print(f"API Key:        {FAIL} no key found (run 'noidea keys add')")
-    except (OSError, json.JSONDecodeError, keyring.errors.KeyringError):
+    except (OSError, ValueError, keyring.errors.KeyringError):
+        # ValueError covers both JSONDecodeError and a malformed stored date.
         console.print(f"API Key:        {FAIL} could not read keys")
 
 
-def status():
+def _confirm_fix(question: str, assume_yes: bool) -> bool:
+    return assume_yes or typer.confirm(question, default=True)
+
+
+def _fix_hook(assume_yes: bool, fixed: list[str], manual: list[str]) -> None:
+    """Reinstall a noidea hook written by an older version; leave other hooks alone."""
+    hooks_dir = get_hooks_dir()
+    if not hooks_dir:
+        return
+    try:
+        state = get_hook_state(hooks_dir)
+    except OSError as error:
+        manual.append(f"Could not read {HOOK_NAME}: {error}")
+        return
+
+    # A missing or foreign hook is a choice the user may have made; only 'init' overrides it.
+    if state == HOOK_STATE_MISSING:
+        manual.append(f"No {HOOK_NAME} hook. Run 'noidea init' to install it.")
+    elif state == HOOK_STATE_FOREIGN:
+        manual.append(f"{HOOK_NAME} belongs to another tool. 'noidea init' replaces it.")
+    elif state == HOOK_STATE_STALE:
+        if not _confirm_fix(f"Reinstall the outdated {HOOK_NAME} hook?", assume_yes):
+            manual.append(f"{HOOK_NAME} hook is still outdated.")
+            return
+        result = install_hook()
+        if result.success:
+            fixed.append(f"Reinstalled the {HOOK_NAME} hook")
+        else:
+            manual.append(f"Couldn't reinstall the hook: {result.error}")
+
+
+def _fix_env_key(assume_yes: bool, fixed: list[str], manual: list[str]) -> None:
+    """Move a key that only lives in the environment or a .env file into the keyring."""
+    env_key = os.environ.get(API_KEY_ENV_VAR)
+    if not env_key:
+        return
+    provider = Provider.ANTHROPIC.value
+    try:
+        if keyring.get_password(SERVICE_NAME, provider):
+            return
+        if not _confirm_fix(f"Move {API_KEY_ENV_VAR} into the system keyring?", assume_yes):
+            manual.append(f"{API_KEY_ENV_VAR} is still only in the environment or a .env file.")
+            return
+        keyring.set_password(SERVICE_NAME, provider, env_key)
+        record_key_date(provider)
+        save_key(provider)
+    except keyring.errors.KeyringError as error:
+        manual.append(f"Couldn't use the keyring: {error}")
+        return
+    except (OSError, json.JSONDecodeError) as error:
+        manual.append(f"Key stored in the keyring, but couldn't register it: {error}")
+        return
+    fixed.append(f"Stored {API_KEY_ENV_VAR} in the system keyring")
+    # Editing the user's .env or shell profile is theirs to do; we only say so.
+    manual.append(f"Remove {API_KEY_ENV_VAR} from your .env file or shell profile.")
+
+
+def _run_fixes(assume_yes: bool) -> None:
+    fixed: list[str] = []
+    manual: list[str] = []
+    _fix_hook(assume_yes, fixed, manual)
+    _fix_env_key(assume_yes, fixed, manual)
+
+    console.print("[bold]Fixes[/bold]")
+    for item in fixed:
+        console.print(f"{OK} {item}")
+    for item in manual:
+        console.print(f"[yellow]![/yellow] {item}")
+    if not fixed and not manual:
+        console.print(f"{OK} Nothing to fix.")
+    console.print()
+
+
+def status(
+    fix: bool = typer.Option(False, "--fix", help="Repair what can be repaired automatically"),
+    yes: bool = typer.Option(False, "--yes", "-y", help="Apply fixes without asking"),
+):
     """Check if everything's wired up and ready to go."""
     console.print(f"\n[bold]noidea[/bold] v{__version__}\n")
     _check_repository()
     _check_hook()
     _config, llm = _check_config()
-    _check_api_keys()
+    _check_api_keys(llm["key_rotation_days"])
     console.print(f"Small Model:    {llm['small_model']}")
     console.print(f"Large Model:    {llm['large_model']}")
     console.print(f"Context Limit:  {llm['context_limit']}")
     console.print(f"Temperature:    {llm['temperature']}")
     console.print()
+    if fix:
+        _run_fixes(yes)
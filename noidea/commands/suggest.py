@@ -1,17 +1,80 @@
-import anthropic
+import json
+import os
+import sys
+
 import typer
 from rich.console import Console
 
-from noidea.config import deep_merge, load_config
-from noidea.git import get_branch_name, get_diff, get_staged_files
-from noidea.provider import get_commit_message
+from noidea.analysis import (
+    DiffAnalysis,
+    analyze_diff,
+    format_analysis,
+    group_diff_by_area,
+    template_for_change,
+    wants_body,
+)
+from noidea.config import (
+    Provider,
+    active_provider,
+    deep_merge,
+    load_config,
+    provider_base_url,
+)
+from noidea.git import (
+    COMMIT_ENV_VAR,
+    HOOK_ENV_VAR,
+    get_amend_diff,
+    get_branch_name,
+    get_diff,
+    get_git_identity,
+    get_head_message,
+    get_staged_files,
+)
+from noidea.message_check import clean_message
+from noidea.policy import disabled_reason
+from noidea.post_process import post_process
+from noidea.provider import get_commit_message, reported_api_errors
+from noidea.secret_scan import SCAN_BLOCK, SCAN_OFF, describe_findings, scan_secrets
+from noidea.trailers import (
+    CHANGE_ID_KEY,
+    SIGN_OFF_KEY,
+    append_trailers,
+    find_trailers,
+    format_sign_off,
+    issue_trailer_for_branch,
+    resolve_configured_trailers,
+)
 
 console = Console(stderr=True)
 
+# Each group is one API request; past this, the smallest areas share a final group.
+SPLIT_GROUPS_MAX = 5
+# Each candidate is one API request too.
+CANDIDATES_COUNT_MAX = 5
+# The first candidate is the one written in --file mode and marked "chosen" in --json output.
+CHOSEN_CANDIDATE_INDEX = 0
+
 
-def _generate_message(diff, config, model, branch, staged_files) -> str | None:
+def _generate_message(
+    diff,
+    config,
+    model,
+    branch,
+    staged_files,
+    change_summary,
+    previous_message,
+    timeout_seconds,
+    body_wanted,
+    template,
+) -> str | None:
     """Call the API and return the commit message, or None on handled error."""
-    try:
+    timeout_message = (
+        f"Timed out after {timeout_seconds}s. Leaving the message for you to write."
+        if timeout_seconds is not None
+        else ""
+    )
+    # Outermost, so the spinner has stopped before an error is printed.
+    with reported_api_errors(timeout_message):
         with console.status("[grey]Thinking of something clever...", spinner="dots"):
             return get_commit_message(
                 diff,
@@ -21,69 +84,382 @@ def _generate_message(diff, config, model, branch, staged_files) -> str | None:
                 branch=branch,
                 staged_files=staged_files,
                 temperature=config["llm"]["temperature"],
+                change_summary=change_summary,
+                previous_message=previous_message,
+                timeout_seconds=timeout_seconds,
+                wants_body=body_wanted,
+                template=template,
+                provider=active_provider(config),
+                base_url=provider_base_url(config),
+                request_log=config["llm"]["request_log"],
             )
-    # Errors handled here (not in provider.py) because each caller needs
-    # different user-facing messages and recovery behavior.
-    except KeyboardInterrupt:
-        raise
-    except anthropic.AuthenticationError as error:
-        print(f"Authentication failed. Check your API key: {error.message}")
-    except anthropic.RateLimitError as error:
-        print(f"Rate limited. Try again shortly: {error.message}")
-    except anthropic.APIConnectionError as error:
-        print(f"Could not connect to the API: {error}")
-    except anthropic.APIStatusError as error:
-        print(f"API error ({error.status_code}): {error.message}")
     return None
 
 
-def _select_model(config: dict, context_length_chars: int) -> str:
+def _post_process(command: str, message: str) -> str:
+    if not command:
+        return message
+    result = post_process(command, message)
+    if result.error:
+        console.print(
+            f"[yellow]llm.post_process failed, using the message as generated:[/yellow]"
+            f" {result.error}"
+        )
+    return result.text
+
+
+def _print_analysis(analysis: DiffAnalysis) -> None:
+    """Show what the model will be told about the diff, file by file."""
+    print(format_analysis(analysis))
+    for change in analysis.files:
+        print(
+            f"  {change.status:<9} {change.category:<7} {change.path}"
+            f" (+{change.lines_added} -{change.lines_removed})"
+        )
+
+
+def _hook_timeout_seconds(config: dict) -> float | None:
+    """Return the hook time budget when running inside the git hook, else no override."""
+    if os.environ.get(HOOK_ENV_VAR) != "1":
+        return None
+    return config["llm"]["hook_timeout_seconds"]
+
+
+def select_model(config: dict, context_length_chars: int) -> str:
     """Pick large or small model based on context size heuristic."""
+    # A local server runs whatever model is pulled; there is no small/large pair to choose from.
+    if active_provider(config) == Provider.OLLAMA:
+        return config["llm"]["ollama_model"]
     if context_length_chars >= config["llm"]["context_limit"]:
         return config["llm"]["large_model"]
     return config["llm"]["small_model"]
 
 
-def suggest(
-    file: str = typer.Option(None, "--file", "-F", help="Write output to a file instead of stdout"),
-    model: str = typer.Option(None, "--model", "-M", help="Run suggestion with a different model"),
-):
-    """Let AI do the thinking. Generates a commit message from your staged changes."""
-    diff = get_diff()
-    if not diff.has_changes:
-        print("Nothing staged yet. Stage some changes first" " — we can't read your mind (yet).")
-        return
+def resolve_sign_off() -> str | None:
+    """Build the Signed-off-by trailer from git config, or None if identity is unset."""
+    name, email = get_git_identity()
+    if not name or not email:
+        print("Can't sign off: set 'git config user.name' and 'git config user.email' first.")
+        return None
+    return format_sign_off(name, email)
 
-    # TigerStyle: validate external data before sending to API.
-    if not diff.diff.strip():
-        print("Staged changes produced an empty diff. Nothing to do.")
-        return
 
-    config = load_config()
+def _read_existing_trailers(path: str) -> list[str]:
+    # 'git commit -s' and Gerrit's amend flow write these before the hook runs.
+    try:
+        with open(path) as f:
+            text = f.read()
+    except OSError:
+        return []
+    # 'git commit -v' appends the diff below a scissors line; a sign-off in its context lines
+    # belongs to someone else and must not become this commit's attestation.
+    text = clean_message(text)
+    return find_trailers(text, SIGN_OFF_KEY) + find_trailers(text, CHANGE_ID_KEY)
+
+
+def _apply_secret_scan(diff_text: str, mode: str) -> str | None:
+    """Return the diff safe to send under the configured mode, or None to send nothing."""
+    if mode == SCAN_OFF:
+        return diff_text
+    scan = scan_secrets(diff_text)
+    if not scan.findings:
+        return diff_text
+    if mode == SCAN_BLOCK:
+        print(f"Possible secrets in the diff: {describe_findings(scan.findings)}.")
+        print("Not sending it (llm.secret_scan is 'block'). Remove them or set it to 'redact'.")
+        return None
+    console.print(
+        f"[yellow]Redacted {len(scan.findings)} possible secret(s) before sending:[/yellow]"
+        f" {describe_findings(scan.findings)}"
+    )
+    return scan.text
+
+
+def _ai_disabled(config: dict, branch: str, paths: list[str]) -> bool:
+    """Report and return True when policy forbids sending this change anywhere."""
+    reason = disabled_reason(
+        branch, paths, config["llm"]["disable_on_branches"], config["llm"]["disable_on_paths"]
+    )
+    if reason is None:
+        return False
+    print(f"AI is off for this change: {reason}. Nothing was sent.")
+    return True
+
+
+def _stdin_is_interactive() -> bool:
+    return sys.stdin.isatty()
+
+
+def _confirm_code_upload(config: dict, assume_yes: bool) -> bool:
+    """Ask before code leaves the machine, when the user opted into that check."""
+    if not config["llm"]["confirm_code_upload"] or assume_yes:
+        return True
+    # Hooks and scripts have no one to answer, so the safe default is to not send.
+    if not _stdin_is_interactive():
+        print("llm.confirm_code_upload is on and there's no terminal to ask. Pass --yes to send.")
+        return False
+    return typer.confirm(f"Send diff to {active_provider(config).value}?", default=False)
+
 
-    # CLI flag config override.
-    if model:
-        config = deep_merge(config, {"llm": {"small_model": model, "large_model": model}})
+def prepare_upload(
+    config: dict, diff_text: str, branch: str, paths: list[str], assume_yes: bool
+) -> str | None:
+    """Return the diff as it may be sent, or None after telling the user why nothing was."""
+    # Policy comes before the upload prompt: a forbidden diff is never offered for sending.
+    if _ai_disabled(config, branch, paths):
+        return None
+    if not _confirm_code_upload(config, assume_yes):
+        print("Nothing was sent.")
+        return None
+    return _apply_secret_scan(diff_text, config["llm"]["secret_scan"])
 
+
+def with_model_override(config: dict, model: str | None) -> dict:
+    """Apply a --model flag to whichever model the config would pick."""
+    if not model:
+        return config
+    return deep_merge(
+        config, {"llm": {"small_model": model, "large_model": model, "ollama_model": model}}
+    )
+
+
+def _with_trailers(message: str, trailers: list[str]) -> str:
+    return append_trailers(message, trailers) if trailers else message
+
+
+def generate_commit_candidates(
+    diff_text: str,
+    model: str | None = None,
+    previous_message: str = "",
+    assume_yes: bool = False,
+    staged_files: list[str] | None = None,
+    count: int = 1,
+    trailers: list[str] | None = None,
+) -> list[str] | None:
+    """Gather repo context for a staged diff and ask the model for up to count messages.
+
+    The given trailers follow the branch's issue reference and precede llm.trailers.
+    """
+    if not isinstance(diff_text, str) or not diff_text.strip():
+        raise ValueError("diff_text must be a non-empty string")
+    if not 1 <= count <= CANDIDATES_COUNT_MAX:
+        raise ValueError(f"count must be between 1 and {CANDIDATES_COUNT_MAX}, got {count}")
+
+    config = load_config()
     branch = get_branch_name()
-    staged_files = get_staged_files()
+    if staged_files is None:
+        staged_files = get_staged_files()
+    # Policy judges the diff actually uploaded: with --amend that spans HEAD^..index, which
+    # covers files the index alone no longer shows as changed.
+    diff_paths = [change.path for change in analyze_diff(diff_text).files]
+    policy_paths = staged_files + [path for path in diff_paths if path not in staged_files]
+    diff_text = prepare_upload(config, diff_text, branch, policy_paths, assume_yes)
+    if diff_text is None:
+        return None
+
+    config = with_model_override(config, model)
     # Character count, not tokens: real tokenization needs the API, but char
     # count is cheap and sufficient for choosing between small and large model.
-    context_length_chars = len(config["llm"]["system_prompt"]) + len(diff.diff)
+    context_length_chars = len(config["llm"]["system_prompt"]) + len(diff_text)
+
+    selected_model = select_model(config, context_length_chars)
+    analysis = analyze_diff(diff_text)
+    change_summary = format_analysis(analysis)
+    body_wanted = wants_body(
+        analysis, config["llm"]["body_min_files"], config["llm"]["body_min_lines"]
+    )
+    template = template_for_change(analysis, config["llm"]["templates"])
+    # Added here, not by the model, so the reference is exact and never invented.
+    issue_trailer = issue_trailer_for_branch(
+        config["llm"]["issue_trailer"], branch, config["llm"]["issue_branch_pattern"]
+    )
+    kept_trailers = ([issue_trailer] if issue_trailer else []) + (trailers or [])
 
-    selected_model = _select_model(config, context_length_chars)
+    candidates = []
+    for _ in range(count):
+        message = _generate_message(
+            diff_text,
+            config,
+            selected_model,
+            branch,
+            staged_files,
+            change_summary,
+            previous_message,
+            _hook_timeout_seconds(config),
+            body_wanted,
+            template,
+        )
+        # The error was already reported; keep whatever candidates arrived before it.
+        if message is None:
+            break
+        # Before trailers, so the user's command can't reword them.
+        message = _post_process(config["llm"]["post_process"], message)
+        configured = resolve_configured_trailers(config["llm"]["trailers"], message, kept_trailers)
+        candidates.append(_with_trailers(message, kept_trailers + configured))
+    return candidates or None
 
-    commit_message = _generate_message(diff.diff, config, selected_model, branch, staged_files)
-    if commit_message is None:
+
+def generate_commit_message(
+    diff_text: str,
+    model: str | None = None,
+    previous_message: str = "",
+    assume_yes: bool = False,
+    staged_files: list[str] | None = None,
+    trailers: list[str] | None = None,
+) -> str | None:
+    """Gather repo context for a staged diff and ask the model for a message."""
+    candidates = generate_commit_candidates(
+        diff_text, model, previous_message, assume_yes, staged_files, trailers=trailers
+    )
+    return candidates[0] if candidates else None
+
+
+def _emit_message(commit_message: str, file: str | None) -> None:
+    """Write the message to the hook's file, or print it for the user."""
+    if not file:
+        print(commit_message)
+        return
+    try:
+        with open(file, "w") as f:
+            f.write(commit_message)
+    except OSError as error:
+        print(f"Could not write to {file}: {error}")
         return
+    console.print("[bold green]Done. You're welcome.[/bold green]")
 
+
+def _emit_candidates(candidates: list[str], file: str | None, as_json: bool) -> None:
+    """Print or write candidates; --file always gets exactly one message."""
+    # Stable shape for editor integrations: add keys if needed, never rename or remove them.
+    payload = json.dumps({"candidates": candidates, "chosen": CHOSEN_CANDIDATE_INDEX})
+    chosen = candidates[CHOSEN_CANDIDATE_INDEX]
     if file:
-        try:
-            with open(file, "w") as f:
-                f.write(commit_message)
-        except OSError as error:
-            print(f"Could not write to {file}: {error}")
-            return
-        console.print("[bold green]Done. You're welcome.[/bold green]")
+        _emit_message(chosen, file)
+        if len(candidates) > 1:
+            print(payload, file=sys.stderr)
+    elif as_json:
+        print(payload)
+    elif len(candidates) == 1:
+        print(chosen)
     else:
-        print(commit_message)
+        print("\n\n".join(f"--- {index} ---\n{text}" for index, text in enumerate(candidates, 1)))
+
+
+def _suggest_split(diff_text: str, model: str | None, assume_yes: bool, trailers: list[str]):
+    """Propose one commit message per top-level area of the staged diff."""
+    groups = group_diff_by_area(diff_text, SPLIT_GROUPS_MAX)
+    config = load_config()
+    # Checked over the whole change: one protected file stops every group, not just its own.
+    all_paths = [path for group in groups for path in group.paths]
+    if _ai_disabled(config, get_branch_name(), all_paths):
+        return
+    if not _confirm_code_upload(config, assume_yes):
+        print("Nothing was sent.")
+        return
+    if len(groups) > 1:
+        console.print(
+            f"[yellow]Splitting into {len(groups)} groups: that's {len(groups)} API requests."
+            "[/yellow]"
+        )
+        print("To commit them separately, run 'git reset' and then, for each group:\n")
+
+    for index, group in enumerate(groups, start=1):
+        # Upload was confirmed once above; don't ask again for every group.
+        message = generate_commit_message(
+            group.diff, model, assume_yes=True, staged_files=group.paths, trailers=trailers
+        )
+        print(f"[{index}/{len(groups)}] {group.area} ({len(group.paths)} files)")
+        print(f"  git add -p -- {' '.join(group.paths)}\n")
+        if message is None:
+            print("  (no message generated for this group)\n")
+            continue
+        print(f"{message}\n")
+
+
+def _read_staged_change(amend: bool) -> tuple[str, str] | None:
+    """Return (diff, message being amended), or None after telling the user why not."""
+    previous_message = ""
+    if amend:
+        previous_message = get_head_message()
+        if not previous_message:
+            print("There's no commit to amend yet.")
+            return None
+        diff = get_amend_diff()
+    else:
+        diff = get_diff()
+    if not diff.has_changes:
+        print("Nothing staged yet. Stage some changes first" " — we can't read your mind (yet).")
+        return None
+
+    # TigerStyle: validate external data before sending to API.
+    if not diff.diff.strip():
+        print("Staged changes produced an empty diff. Nothing to do.")
+        return None
+    return diff.diff, previous_message
+
+
+def suggest(
+    file: str = typer.Option(None, "--file", "-F", help="Write output to a file instead of stdout"),
+    model: str = typer.Option(None, "--model", "-M", help="Run suggestion with a different model"),
+    show_analysis: bool = typer.Option(
+        False, "--show-analysis", help="Print the local diff analysis without calling the API"
+    ),
+    amend: bool = typer.Option(False, "--amend", help="Refine the HEAD commit's message"),
+    yes: bool = typer.Option(
+        False, "--yes", "-y", help="Send the diff without asking (llm.confirm_code_upload)"
+    ),
+    sign_off: bool = typer.Option(
+        False, "--sign-off", "-s", help="Append a Signed-off-by trailer from git config"
+    ),
+    split: bool = typer.Option(
+        False, "--split", help="Propose one commit per top-level area of the staged diff"
+    ),
+    count: int = typer.Option(
+        1, "--count", "-n", min=1, max=CANDIDATES_COUNT_MAX, help="Number of candidates"
+    ),
+    as_json: bool = typer.Option(
+        False, "--json", help='Print {"candidates": [...], "chosen": 0} for editors'
+    ),
+):
+    """Let AI do the thinking. Generates a commit message from your staged changes."""
+    if file and os.environ.get(COMMIT_ENV_VAR) == "1":
+        # A stale hook under 'noidea commit': the file already holds the confirmed message.
+        return
+    if split and (file or amend or as_json or count > 1):
+        print("--split prints one message per group; it can't be combined with --file, --amend,")
+        print("--json or --count.")
+        return
+    change = _read_staged_change(amend)
+    if change is None:
+        return
+    diff_text, previous_message = change
+
+    if show_analysis:
+        _print_analysis(analyze_diff(diff_text))
+        return
+
+    # Resolve before the API call so a missing identity doesn't waste a request.
+    trailers = _read_existing_trailers(file) if file else []
+    if amend:
+        # Gerrit matches an amend to its review by Change-Id, so it must survive the rewrite.
+        trailers += find_trailers(previous_message, CHANGE_ID_KEY)
+    if sign_off:
+        sign_off_trailer = resolve_sign_off()
+        if sign_off_trailer is None:
+            return
+        trailers.append(sign_off_trailer)
+
+    if split:
+        _suggest_split(diff_text, model, yes, trailers)
+        return
+    # The amended commit's files, not just what is staged on top of it.
+    staged_files = [change.path for change in analyze_diff(diff_text).files] if amend else None
+    candidates = generate_commit_candidates(
+        diff_text, model, previous_message, yes, staged_files, count=count, trailers=trailers
+    )
+    if candidates is None:
+        # Scripts reading --json need a failure signal; the hook path must never fail a commit.
+        if as_json:
+            raise typer.Exit(1)
+        return
+    _emit_candidates(candidates, file, as_json)
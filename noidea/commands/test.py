@@ -1,10 +1,9 @@
 import random
 
-import anthropic
 from rich.console import Console
 
-from noidea.config import load_config
-from noidea.provider import get_commit_message
+from noidea.config import Provider, active_provider, load_config, provider_base_url
+from noidea.provider import get_commit_message, reported_api_errors
 
 console = Console()
 
@@ -32,31 +31,24 @@ def test():
     config = load_config()
     llm = config["llm"]
     topic = random.choice(JOKE_TOPICS)
+    provider = active_provider(config)
+    model = llm["ollama_model"] if provider == Provider.OLLAMA else llm["large_model"]
 
-    try:
+    test_msg = None
+    with reported_api_errors():
         with console.status("[grey]Checking systems...", spinner="dots"):
             test_msg = get_commit_message(
                 diff=f"tell a creative short coding joke about {topic}",
                 system_prompt="only output the joke nothing else. "
                 "be original and avoid cliché jokes.",
-                model=llm["large_model"],
+                model=model,
                 max_tokens=llm["max_tokens"],
                 temperature=1.0,
+                provider=provider,
+                base_url=provider_base_url(config),
+                request_log=llm["request_log"],
             )
-    # Same API error pattern as suggest.py, with messages suited to the test context.
-    except KeyboardInterrupt:
-        raise
-    except anthropic.AuthenticationError as error:
-        print(f"Authentication failed. Check your API key: {error.message}")
-        return
-    except anthropic.RateLimitError as error:
-        print(f"Rate limited. Try again shortly: {error.message}")
-        return
-    except anthropic.APIConnectionError as error:
-        print(f"Couldn't reach the API: {error}")
-        return
-    except anthropic.APIStatusError as error:
-        print(f"API error ({error.status_code}): {error.message}")
+    if test_msg is None:
         return
 
     print("The AI is alive and well.")
@@ -1,22 +1,47 @@
 import subprocess
 import sys
+import time
 
 import typer
 
+UPDATE_TIMEOUT_SECONDS_DEFAULT = 30.0
 
-def update():
-    """Get the latest noidea — now with even less idea required."""
+
+def _run_upgrade(deadline: float) -> None:
+    """Upgrade with pipx, or pip without it; both share one deadline."""
     try:
-        subprocess.run(["pipx", "upgrade", "noidea"], check=True)
+        subprocess.run(
+            ["pipx", "upgrade", "noidea"], check=True, timeout=deadline - time.monotonic()
+        )
     except FileNotFoundError:
-        # pipx not available, fall back to pip
+        # pipx not available, fall back to pip. A failing pip is left to the caller, like pipx.
         try:
             subprocess.run(
                 [sys.executable, "-m", "pip", "install", "--upgrade", "noidea"],
                 check=True,
+                timeout=deadline - time.monotonic(),
             )
-        except (subprocess.CalledProcessError, FileNotFoundError) as e:
-            print(f"Update failed: {e}")
+        except FileNotFoundError as e:
+            typer.echo(f"Update failed: {e}", err=True)
+            raise typer.Exit(1)
+
+
+def update(
+    timeout: float = typer.Option(
+        UPDATE_TIMEOUT_SECONDS_DEFAULT,
+        "--timeout",
+        min=1,
+        help="Give up after this many seconds, counting the pip fallback",
+    ),
+):
+    """Get the latest noidea — now with even less idea required."""
+    # One budget for the whole update, so a stalled index can't hang a script.
+    deadline = time.monotonic() + timeout
+    try:
+        _run_upgrade(deadline)
+    except subprocess.TimeoutExpired:
+        typer.echo(f"Update timed out after {timeout:g}s. Check your connection.", err=True)
+        raise typer.Exit(1)
     except subprocess.CalledProcessError as e:
         typer.echo(f"Update failed: {e}", err=True)
         raise typer.Exit(1)
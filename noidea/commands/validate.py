@@ -0,0 +1,95 @@
+import typer
+
+from noidea.config import load_config
+from noidea.git import get_commit_messages
+from noidea.message_check import check_message
+from noidea.trailers import closing_references
+
+SHORT_SHA_LENGTH = 7
+
+
+def _check(message: str, llm: dict) -> list[str]:
+    return check_message(
+        message, llm["allowed_scopes"], llm["message_pattern"], llm["message_pattern_example"]
+    )
+
+
+def _validate_file(path: str, llm: dict) -> bool:
+    try:
+        with open(path) as f:
+            text = f.read()
+    except OSError as error:
+        print(f"Could not read {path}: {error}")
+        raise typer.Exit(1)
+
+    problems = _check(text, llm)
+    if not problems:
+        print("✓ Message looks good.")
+        return True
+    print(f"✗ {path}:")
+    for problem in problems:
+        print(f"  - {problem}")
+    return False
+
+
+def _report_closing(commits: list) -> None:
+    # Informational: lets a pre-push hook show what GitHub will close before it happens.
+    closed = {}
+    for commit in commits:
+        for number in closing_references(commit.message):
+            closed.setdefault(number, commit.sha[:SHORT_SHA_LENGTH])
+    if closed:
+        listed = ", ".join(f"#{number} ({sha})" for number, sha in closed.items())
+        print(f"Closes on GitHub once merged: {listed}")
+
+
+def _validate_range(rev_range: str, llm: dict) -> bool:
+    log = get_commit_messages(rev_range, llm["range_commits_max"])
+    if not log.success:
+        print(f"Could not read {rev_range}: {log.error}")
+        raise typer.Exit(1)
+    if not log.commits:
+        print(f"No commits in {rev_range}.")
+        return True
+    if log.truncated:
+        of_total = f" of {log.total}" if log.total else ""
+        print(f"Only checking the newest {len(log.commits)}{of_total} commits in {rev_range}.")
+
+    # Report every failing commit, not just the first: CI output should be the full to-do list.
+    failed = 0
+    for commit in log.commits:
+        problems = _check(commit.message, llm)
+        if not problems:
+            continue
+        failed += 1
+        subject = commit.message.splitlines()[0] if commit.message else ""
+        print(f"✗ {commit.sha[:SHORT_SHA_LENGTH]} {subject}")
+        for problem in problems:
+            print(f"  - {problem}")
+    _report_closing(log.commits)
+
+    if failed:
+        print(f"\n{failed} of {len(log.commits)} commit messages need work.")
+        return False
+    print(f"✓ All {len(log.commits)} commit messages look good.")
+    return True
+
+
+def validate(
+    file: str = typer.Option(None, "--file", "-F", help="Check the message in this file"),
+    rev_range: str = typer.Option(
+        None, "--range", help="Check every commit in a range, e.g. origin/main..HEAD"
+    ),
+):
+    """Check commit messages against the conventional format. Exits 1 on any violation."""
+    if (file is None) == (rev_range is None):
+        print("Pass exactly one of --file or --range.")
+        raise typer.Exit(1)
+
+    llm = load_config()["llm"]
+    if file is not None:
+        passed = _validate_file(file, llm)
+    else:
+        passed = _validate_range(rev_range, llm)
+    if not passed:
+        raise typer.Exit(1)
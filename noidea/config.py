@@ -1,20 +1,49 @@
-"""Three-tier configuration: built-in defaults, user overrides, repo overrides."""
+"""Layered configuration: built-in defaults, system, user and repo overrides."""
 
 import json
 import os
 import sys
+from datetime import datetime, timezone
 from enum import Enum
+from urllib.parse import urlparse
 
-from noidea.git import get_git_root
+from noidea.analysis import TEMPLATE_KEYS
+from noidea.git import RANGE_COMMITS_MAX, get_git_root
+from noidea.message_check import check_message_pattern
+from noidea.post_process import check_post_process_command
+from noidea.secret_scan import SCAN_MODES, SCAN_REDACT
+from noidea.trailers import (
+    ISSUE_TRAILER_MODES,
+    ISSUE_TRAILER_OFF,
+    check_branch_pattern,
+    check_configured_trailer,
+)
 
 SERVICE_NAME = "noidea"
 CONFIG_DIR_NAME = ".noidea"
 CONFIG_FILENAME = "config.json"
 KEYS_FILENAME = "keys.json"
+KEY_DATES_FILENAME = "key_dates.json"
+MODELS_CACHE_FILENAME = "models_cache.json"
 
 CONFIG_DIR = os.path.expanduser(f"~/{CONFIG_DIR_NAME}")
 CONFIG_PATH = os.path.join(CONFIG_DIR, CONFIG_FILENAME)
 KEYS_PATH = os.path.join(CONFIG_DIR, KEYS_FILENAME)
+KEY_DATES_PATH = os.path.join(CONFIG_DIR, KEY_DATES_FILENAME)
+MODELS_CACHE_PATH = os.path.join(CONFIG_DIR, MODELS_CACHE_FILENAME)
+
+# Machine-wide baseline an admin can ship to every user; the env var points elsewhere.
+SYSTEM_CONFIG_PATH = os.path.join("/etc", "noidea", CONFIG_FILENAME)
+SYSTEM_CONFIG_ENV_VAR = "NOIDEA_SYSTEM_CONFIG"
+
+# The model list changes rarely; an hour keeps repeated lookups off the network.
+MODELS_CACHE_MAX_AGE_SECONDS = 3600
+
+
+class Provider(str, Enum):
+    ANTHROPIC = "anthropic"
+    OLLAMA = "ollama"
+
 
 DEFAULTS = {
     "llm": {
@@ -33,7 +62,68 @@ DEFAULTS = {
             "one action per bullet. Keep each line under 72 chars. No fluff.\n"
             "Output only the raw commit message."
         ),
+        # System prompt for 'noidea pr-description', which sees commits and a combined diff.
+        "pr_description_prompt": (
+            "Write a pull request description from the commit messages and combined diff.\n"
+            "Start with a short paragraph: what the change does and why.\n"
+            "Then a '## Changes' section with one bullet per change.\n"
+            "Then a '## Testing' section: how it was or should be verified, "
+            "based on the tests in the diff.\n"
+            "Markdown, no title line, no fluff. Output only the description."
+        ),
+        # System prompt for 'noidea review', which sees one commit's message and full diff.
+        "review_prompt": (
+            "Review this commit as an experienced engineer would in code review.\n"
+            "Give up to 7 actionable points on the actual changes: bugs, edge cases, "
+            "missing tests, unclear names, and whether the message matches the diff.\n"
+            "One bullet per point: the file, what to change, and why.\n"
+            "If nothing needs changing, say so in one line.\n"
+            "No praise, no summary of the diff. Output only the review."
+        ),
         "temperature": 1.0,
+        # Hooks run inside 'git commit'; a short budget keeps a slow provider from hanging it.
+        "hook_timeout_seconds": 8,
+        # Age after which a stored API key is flagged for rotation; 0 disables the reminder.
+        "key_rotation_days": 90,
+        # Privacy opt-in: ask before any diff is sent to the provider.
+        "confirm_code_upload": False,
+        # Regex every message must contain a match for, e.g. "[A-Z]+-[0-9]+" for a ticket key;
+        # "" turns it off. The example is shown when a message fails it.
+        "message_pattern": "",
+        "message_pattern_example": "",
+        # What to do with likely secrets in a diff before it is sent: redact, block or off.
+        "secret_scan": SCAN_REDACT,
+        # Append "Refs #N" or "Closes #N" when the branch is named after issue N: off, refs, closes.
+        "issue_trailer": ISSUE_TRAILER_OFF,
+        # Regex with one group around the issue number, e.g. "^JIRA-([0-9]+)"; "" is built in.
+        "issue_branch_pattern": "",
+        # Globs for branches and staged paths where no diff may ever be sent to the provider.
+        "disable_on_branches": [],
+        "disable_on_paths": [],
+        # Changes at or above either size get asked for a body, smaller ones for a subject only.
+        # 0 ignores that measure; both 0 leaves the decision to system_prompt.
+        "body_min_files": 5,
+        "body_min_lines": 150,
+        # "Key: value" trailers appended to every message; "Change-Id: <generated>" for Gerrit.
+        "trailers": [],
+        # Where messages come from: "anthropic", or "ollama" for a local model with no API key.
+        "provider": Provider.ANTHROPIC.value,
+        "ollama_base_url": "http://localhost:11434",
+        "ollama_model": "llama3.2",
+        # Anthropic endpoint for a corporate proxy or gateway; "" uses the SDK's default.
+        "base_url": "",
+        # Command each generated message is piped through, e.g. "my-formatter --wrap 72"; ""
+        # turns it off. Its stdout replaces the message; on any failure the message is kept.
+        "post_process": "",
+        # File each request and response is appended to as a JSON line, secrets redacted; ""
+        # turns it off. For debugging prompts; --log-requests sets it for one run.
+        "request_log": "",
+        # Message templates by change type (code, docs, tests, config, ci, mixed), or "default".
+        "templates": {},
+        # Scopes 'noidea validate' accepts in type(scope): ...; empty allows any scope.
+        "allowed_scopes": [],
+        # Most commits 'validate --range' and 'pr-description' read; the newest are kept.
+        "range_commits_max": RANGE_COMMITS_MAX,
     }
 }
 
@@ -44,12 +134,122 @@ _LLM_SCHEMA = {
     "large_model": str,
     "context_limit": (int, float),
     "system_prompt": str,
+    "pr_description_prompt": str,
+    "review_prompt": str,
     "temperature": (int, float),
+    "hook_timeout_seconds": (int, float),
+    "key_rotation_days": int,
+    "confirm_code_upload": bool,
+    "message_pattern": str,
+    "message_pattern_example": str,
+    "secret_scan": str,
+    "issue_trailer": str,
+    "issue_branch_pattern": str,
+    "disable_on_branches": list,
+    "disable_on_paths": list,
+    "body_min_files": int,
+    "body_min_lines": int,
+    "trailers": list,
+    "templates": dict,
+    "post_process": str,
+    "request_log": str,
+    "allowed_scopes": list,
+    "range_commits_max": int,
+    "provider": str,
+    "ollama_base_url": str,
+    "ollama_model": str,
+    "base_url": str,
 }
 
+# Integer keys with a lowest allowed value; body thresholds use 0 to mean "ignore".
+_LLM_MINIMUMS = {
+    "body_min_files": 0,
+    "body_min_lines": 0,
+    "range_commits_max": 1,
+}
+
+# Number keys that must be above zero. 0 would read as "no timeout" but fail every hook request.
+_LLM_POSITIVE = ("hook_timeout_seconds",)
+
+# String keys that only accept a fixed set of values.
+_LLM_CHOICES = {
+    "secret_scan": SCAN_MODES,
+    "issue_trailer": ISSUE_TRAILER_MODES,
+    "provider": tuple(provider.value for provider in Provider),
+}
+
+
+# Plain http is only safe when the traffic never leaves the machine.
+_LOOPBACK_HOSTS = ("localhost", "127.0.0.1", "::1")
+
+
+def check_base_url(url: str) -> str | None:
+    """Return what is wrong with a custom endpoint, or None when it is usable."""
+    parsed = urlparse(url)
+    if parsed.scheme not in ("https", "http") or not parsed.hostname:
+        return f"not a well-formed URL: {url!r}"
+    if parsed.scheme == "http" and parsed.hostname not in _LOOPBACK_HOSTS:
+        return f"must use https unless it is on this machine: {url!r}"
+    return None
+
+
+def active_provider(config: dict) -> Provider:
+    return Provider(config["llm"]["provider"])
+
+
+def provider_base_url(config: dict) -> str | None:
+    """Return the endpoint to call instead of the SDK's default, or None for the default."""
+    if active_provider(config) == Provider.OLLAMA:
+        return config["llm"]["ollama_base_url"]
+    return config["llm"]["base_url"] or None
+
+
+def _check_trailers(templates: list[str]) -> str | None:
+    for template in templates:
+        problem = check_configured_trailer(template)
+        if problem is not None:
+            return f"has an invalid entry: {problem}"
+    return None
+
+
+def _check_templates(templates: dict) -> str | None:
+    if all(
+        key in TEMPLATE_KEYS and isinstance(text, str) and text.strip()
+        for key, text in templates.items()
+    ):
+        return None
+    return f"keys must be {', '.join(TEMPLATE_KEYS)} with non-empty text"
+
+
+# Checks for keys whose set value needs more than a type; each returns what is wrong, or None.
+# An empty value is the "off" setting and is never checked.
+_LLM_CHECKS = {
+    "trailers": _check_trailers,
+    "templates": _check_templates,
+    "post_process": check_post_process_command,
+    "issue_branch_pattern": check_branch_pattern,
+    "base_url": check_base_url,
+    "message_pattern": check_message_pattern,
+}
 
-class Provider(str, Enum):
-    ANTHROPIC = "anthropic"
+
+def _llm_problem(llm: dict, key: str) -> str | None:
+    """Return what is wrong with one merged llm value, or None when it is usable."""
+    value = llm.get(key)
+    expected_type = _LLM_SCHEMA[key]
+    if not isinstance(value, expected_type):
+        return f"has wrong type ({type(value).__name__})"
+    if expected_type is list and not all(isinstance(item, str) for item in value):
+        return "must be a list of strings"
+    if key in _LLM_MINIMUMS and value < _LLM_MINIMUMS[key]:
+        return f"must be at least {_LLM_MINIMUMS[key]}"
+    if key in _LLM_POSITIVE and value <= 0:
+        return "must be positive"
+    if key in _LLM_CHOICES and value not in _LLM_CHOICES[key]:
+        return f"must be one of {', '.join(_LLM_CHOICES[key])} (got {value!r})"
+    if key in _LLM_CHECKS and value:
+        return _LLM_CHECKS[key](value)
+    return None
 
 
 def validate_config(config: dict) -> dict:
@@ -62,13 +262,10 @@ def validate_config(config: dict) -> dict:
         )
         return DEFAULTS.copy()
 
-    for key, expected_type in _LLM_SCHEMA.items():
-        value = llm.get(key)
-        if not isinstance(value, expected_type):
-            print(
-                f"Warning: llm.{key} has wrong type" f" ({type(value).__name__}), using default.",
-                file=sys.stderr,
-            )
+    for key in _LLM_SCHEMA:
+        problem = _llm_problem(llm, key)
+        if problem is not None:
+            print(f"Warning: llm.{key} {problem}, using default.", file=sys.stderr)
             llm[key] = DEFAULTS["llm"][key]
 
     return config
@@ -91,21 +288,33 @@ def deep_merge(base, override):
     return result
 
 
+def get_system_config_path() -> str:
+    return os.environ.get(SYSTEM_CONFIG_ENV_VAR) or SYSTEM_CONFIG_PATH
+
+
+def get_repo_config_path() -> str | None:
+    repo_root = get_git_root()
+    if not repo_root:
+        return None
+    return os.path.join(repo_root, CONFIG_DIR_NAME, CONFIG_FILENAME)
+
+
 def _collect_config_paths() -> list[str]:
-    """Gather user and repo config file paths that exist on disk."""
+    """Gather system, user and repo config file paths that exist on disk, lowest first."""
     paths = []
+    system_path = get_system_config_path()
+    if os.path.exists(system_path):
+        paths.append(system_path)
     if os.path.exists(CONFIG_PATH):
         paths.append(CONFIG_PATH)
-    repo_root = get_git_root()
-    if repo_root:
-        repo_path = os.path.join(repo_root, CONFIG_DIR_NAME, CONFIG_FILENAME)
-        if os.path.exists(repo_path):
-            paths.append(repo_path)
+    repo_path = get_repo_config_path()
+    if repo_path and os.path.exists(repo_path):
+        paths.append(repo_path)
     return paths
 
 
 def load_config() -> dict:
-    # Merge order: defaults → user config → repo config (last wins).
+    # Merge order: defaults → system config → user config → repo config (last wins).
     config = DEFAULTS
     for path in _collect_config_paths():
         try:
@@ -118,6 +327,36 @@ def load_config() -> dict:
     return config
 
 
+def parse_dotted_key(dotted_key: str) -> tuple[str, str]:
+    """Split "llm.max_tokens" into its section and key."""
+    section, separator, key = dotted_key.partition(".")
+    if not separator or not section or not key:
+        raise ValueError(f"keys look like 'llm.max_tokens', got {dotted_key!r}")
+    return section, key
+
+
+def reset_config_keys(path: str, dotted_keys: list[str]) -> list[str]:
+    """Remove "section.key" entries from a config file; return the ones that were set."""
+    parsed = [parse_dotted_key(dotted_key) for dotted_key in dotted_keys]
+    with open(path) as f:
+        config = json.load(f)
+
+    removed = []
+    for dotted_key, (section, key) in zip(dotted_keys, parsed):
+        if isinstance(config.get(section), dict) and key in config[section]:
+            del config[section][key]
+            removed.append(dotted_key)
+    with open(path, "w") as f:
+        json.dump(config, f, indent=2)
+    return removed
+
+
+def reset_config(path: str) -> None:
+    """Empty a config file, as on first run, so system config and defaults show through."""
+    with open(path, "w") as f:
+        json.dump({}, f)
+
+
 def initialize():
     try:
         os.makedirs(CONFIG_DIR, exist_ok=True)
@@ -127,8 +366,9 @@ def initialize():
 
     if not os.path.exists(CONFIG_PATH):
         try:
+            # Overrides only: a copy of DEFAULTS here would hide every system config value.
             with open(CONFIG_PATH, "w") as f:
-                json.dump(DEFAULTS, f, indent=2)
+                json.dump({}, f)
         except OSError as error:
             print(f"Warning: could not write {CONFIG_PATH}: {error}", file=sys.stderr)
 
@@ -165,3 +405,69 @@ def remove_key(name: str):
 def list_keys() -> list:
     with open(KEYS_PATH) as f:
         return json.load(f)
+
+
+def _read_key_dates() -> dict:
+    # Keys added before age tracking existed have no entry; a missing file is not an error.
+    if not os.path.exists(KEY_DATES_PATH):
+        return {}
+    with open(KEY_DATES_PATH) as f:
+        return json.load(f)
+
+
+def record_key_date(name: str, now: datetime | None = None) -> None:
+    """Remember when a key was last set. Replacing a key restarts its age."""
+    if not isinstance(name, str) or not name:
+        raise ValueError("name must be a non-empty string")
+    dates = _read_key_dates()
+    dates[name] = (now or datetime.now(timezone.utc)).isoformat()
+    with open(KEY_DATES_PATH, "w") as f:
+        json.dump(dates, f)
+
+
+def forget_key_date(name: str) -> None:
+    dates = _read_key_dates()
+    if dates.pop(name, None) is None:
+        return
+    with open(KEY_DATES_PATH, "w") as f:
+        json.dump(dates, f)
+
+
+def get_key_age_days(name: str, now: datetime | None = None) -> int | None:
+    """Whole days since the key was configured, or None if its date is unknown."""
+    added = _read_key_dates().get(name)
+    if added is None:
+        return None
+    age = (now or datetime.now(timezone.utc)) - datetime.fromisoformat(added)
+    return max(age.days, 0)
+
+
+def read_models_cache(base_url: str, now: datetime | None = None) -> list[str] | None:
+    """Return cached model IDs if the cache is fresh and from base_url, else None."""
+    if not os.path.exists(MODELS_CACHE_PATH):
+        return None
+    with open(MODELS_CACHE_PATH) as f:
+        cache = json.load(f)
+    # A gateway may offer other models than the public API; a cache without a URL predates this.
+    if cache.get("base_url") != base_url:
+        return None
+    fetched_at = datetime.fromisoformat(cache["fetched_at"])
+    age_seconds = ((now or datetime.now(timezone.utc)) - fetched_at).total_seconds()
+    if age_seconds < 0 or age_seconds >= MODELS_CACHE_MAX_AGE_SECONDS:
+        return None
+    return cache["models"]
+
+
+def write_models_cache(models: list[str], base_url: str, now: datetime | None = None) -> None:
+    """Cache model IDs with the endpoint they came from; "" is the public API."""
+    if not isinstance(models, list) or not models:
+        raise ValueError("models must be a non-empty list")
+    if not isinstance(base_url, str):
+        raise TypeError(f"base_url must be a string, got {type(base_url).__name__}")
+    cache = {
+        "fetched_at": (now or datetime.now(timezone.utc)).isoformat(),
+        "base_url": base_url,
+        "models": models,
+    }
+    with open(MODELS_CACHE_PATH, "w") as f:
+        json.dump(cache, f)
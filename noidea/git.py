@@ -2,7 +2,7 @@
 
 import os
 import subprocess
-from dataclasses import dataclass
+from dataclasses import dataclass, field
 
 
 @dataclass
@@ -18,9 +18,64 @@ class HookResult:
     error: str = ""
 
 
+@dataclass
+class CommitResult:
+    success: bool
+    output: str = ""
+    error: str = ""
+
+
+@dataclass
+class LoggedCommit:
+    sha: str
+    message: str
+
+
+@dataclass
+class LogResult:
+    success: bool
+    commits: list[LoggedCommit] = field(default_factory=list)
+    truncated: bool = False
+    # Commits in the whole range when truncated; 0 if unknown or not truncated.
+    total: int = 0
+    error: str = ""
+
+
+# Git's well-known hash of the empty tree, used as the diff base when amending a root commit.
+EMPTY_TREE_HASH = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+# Default bound on a range walk: 'main' typed for 'main..HEAD' would otherwise read the whole
+# history. Overridden by llm.range_commits_max.
+RANGE_COMMITS_MAX = 500
+
+# Unit and record separators cannot appear in a commit hash and practically never in a message.
+_LOG_FIELD_SEPARATOR = "\x1f"
+_LOG_RECORD_SEPARATOR = "\x1e"
+
 HOOK_NAME = "prepare-commit-msg"
 HOOK_BACKUP_SUFFIX = ".bak"
-HOOK_SCRIPT = '#!/bin/bash\nnoidea suggest --file "$1"\n'
+HOOK_ENV_VAR = "NOIDEA_HOOK"
+# Every script noidea has installed runs this command; other hooks never do.
+HOOK_MARKER = "noidea suggest"
+
+# Set on the git commit that 'noidea commit' runs. A stale hook without the "$2" guard below
+# still calls 'suggest --file', which must then keep the message the user already confirmed.
+COMMIT_ENV_VAR = "NOIDEA_COMMIT"
+
+HOOK_STATE_MISSING = "missing"
+HOOK_STATE_CURRENT = "current"
+HOOK_STATE_STALE = "stale"
+HOOK_STATE_FOREIGN = "foreign"
+
+# Source "message" means -m/-F already supplied the text (e.g. 'noidea commit'): leave it alone.
+# The env var tells suggest it runs inside a hook, where a slow provider must not block commits.
+HOOK_SCRIPT = (
+    "#!/bin/bash\n"
+    'if [ "$2" = "message" ]; then\n'
+    "    exit 0\n"
+    "fi\n"
+    f'{HOOK_ENV_VAR}=1 noidea suggest --file "$1"\n'
+)
 
 # TigerStyle: compile-time-style constant assertion.
 if not HOOK_SCRIPT.strip():
@@ -53,6 +108,21 @@ def get_branch_name() -> str:
     return result.stdout.strip()
 
 
+def get_git_identity() -> tuple[str, str]:
+    """Return (user.name, user.email) from git config, empty strings when unset."""
+    values = []
+    for key in ("user.name", "user.email"):
+        # check=False: an unset key exits 1, which the caller reports as missing identity.
+        result = subprocess.run(
+            ["git", "config", key],
+            text=True,
+            capture_output=True,
+            check=False,
+        )
+        values.append(result.stdout.strip() if result.returncode == 0 else "")
+    return values[0], values[1]
+
+
 def get_staged_files() -> list[str]:
     # check=False: returns empty list if nothing is staged or git is missing.
     result = subprocess.run(
@@ -64,12 +134,13 @@ def get_staged_files() -> list[str]:
     return [f for f in result.stdout.strip().splitlines() if f]
 
 
-def get_diff() -> DiffResult:
+def _run_staged_diff(base: str | None) -> DiffResult:
+    command = ["git", "diff", "--staged"]
+    if base:
+        command.append(base)
     try:
         # check=True: staged diff is required for the core feature, so failure is an error.
-        result = subprocess.run(
-            ["git", "diff", "--staged"], capture_output=True, text=True, check=True
-        )
+        result = subprocess.run(command, capture_output=True, text=True, check=True)
 
         if not result.stdout:
             return DiffResult(has_changes=False)
@@ -83,6 +154,156 @@ def get_diff() -> DiffResult:
         return DiffResult(has_changes=False, error=str(e))
 
 
+def get_diff() -> DiffResult:
+    return _run_staged_diff(None)
+
+
+def get_amend_diff() -> DiffResult:
+    """Diff the amended commit would contain: HEAD's parent against the index."""
+    # check=False: a missing parent is expected for the root commit.
+    parent = subprocess.run(
+        ["git", "rev-parse", "--verify", "--quiet", "HEAD^"],
+        text=True,
+        capture_output=True,
+        check=False,
+    )
+    base = parent.stdout.strip() if parent.returncode == 0 else EMPTY_TREE_HASH
+    return _run_staged_diff(base)
+
+
+def get_head_message() -> str:
+    # check=False: an empty result tells the caller there is no commit to amend.
+    result = subprocess.run(
+        ["git", "log", "-1", "--format=%B"],
+        text=True,
+        capture_output=True,
+        check=False,
+    )
+    if result.returncode != 0:
+        return ""
+    return result.stdout.strip()
+
+
+def count_commits(rev_range: str) -> int:
+    """Count the commits in a range without reading their messages; 0 if git can't."""
+    try:
+        result = subprocess.run(
+            ["git", "rev-list", "--count", rev_range, "--"],
+            text=True,
+            capture_output=True,
+            check=False,
+        )
+    except FileNotFoundError:
+        return 0
+    if result.returncode != 0 or not result.stdout.strip().isdigit():
+        return 0
+    return int(result.stdout.strip())
+
+
+def get_commit_messages(rev_range: str, commits_max: int = RANGE_COMMITS_MAX) -> LogResult:
+    """Read the message of at most commits_max commits in a revision range, newest first."""
+    if not isinstance(rev_range, str) or not rev_range.strip():
+        raise ValueError("rev_range must be a non-empty string")
+    if not isinstance(commits_max, int) or commits_max < 1:
+        raise ValueError("commits_max must be a positive integer")
+
+    # git stops walking at the cap, so a huge history is never read into memory. One past the
+    # cap tells a full range apart from a truncated one.
+    command = [
+        "git",
+        "log",
+        f"--max-count={commits_max + 1}",
+        f"--format=%H{_LOG_FIELD_SEPARATOR}%B{_LOG_RECORD_SEPARATOR}",
+        rev_range,
+        "--",
+    ]
+    try:
+        # check=False: an unknown revision is reported to the user with git's own message.
+        result = subprocess.run(command, text=True, capture_output=True, check=False)
+    except FileNotFoundError as e:
+        return LogResult(success=False, error=str(e))
+    if result.returncode != 0:
+        return LogResult(success=False, error=result.stderr.strip())
+
+    commits = []
+    for record in result.stdout.split(_LOG_RECORD_SEPARATOR):
+        sha, separator, message = record.strip().partition(_LOG_FIELD_SEPARATOR)
+        if separator:
+            commits.append(LoggedCommit(sha=sha, message=message.strip()))
+    if len(commits) <= commits_max:
+        return LogResult(success=True, commits=commits)
+    return LogResult(
+        success=True,
+        commits=commits[:commits_max],
+        truncated=True,
+        total=count_commits(rev_range),
+    )
+
+
+def get_range_diff(rev_range: str) -> DiffResult:
+    """Diff a BASE..HEAD range as a pull request shows it: HEAD against its merge base."""
+    if not isinstance(rev_range, str):
+        raise TypeError(f"rev_range must be a string, got {type(rev_range).__name__}")
+    base, separator, head = rev_range.partition("..")
+    if not separator or not base or head.startswith("."):
+        raise ValueError(f"rev_range must look like BASE..HEAD, got {rev_range!r}")
+
+    command = ["git", "diff", f"{base}...{head or 'HEAD'}", "--"]
+    try:
+        # check=False: an unknown revision is reported to the user with git's own message.
+        result = subprocess.run(command, text=True, capture_output=True, check=False)
+    except FileNotFoundError as e:
+        return DiffResult(has_changes=False, error=str(e))
+    if result.returncode != 0:
+        return DiffResult(has_changes=False, error=result.stderr.strip())
+    return DiffResult(has_changes=bool(result.stdout), diff=result.stdout)
+
+
+def get_commit_diff(revision: str) -> DiffResult:
+    """Return the patch a single commit introduced, against its first parent.
+
+    For a merge that is everything the merge brought in, as a plain diff.
+    """
+    if not isinstance(revision, str) or not revision.strip():
+        raise ValueError("revision must be a non-empty string")
+
+    # --format= drops the header; the caller reads the message separately. Without
+    # --diff-merges a merge shows as a combined "diff --cc", which has no paths to check
+    # against llm.disable_on_paths.
+    command = ["git", "show", "--format=", "--patch", "--diff-merges=first-parent", revision, "--"]
+    try:
+        # check=False: an unknown revision is reported to the user with git's own message.
+        result = subprocess.run(command, text=True, capture_output=True, check=False)
+    except FileNotFoundError as e:
+        return DiffResult(has_changes=False, error=str(e))
+    if result.returncode != 0:
+        return DiffResult(has_changes=False, error=result.stderr.strip())
+    return DiffResult(has_changes=bool(result.stdout.strip()), diff=result.stdout)
+
+
+def commit_with_message(message: str) -> CommitResult:
+    """Commit the staged changes with the given message, bypassing the editor."""
+    if not isinstance(message, str) or not message.strip():
+        raise ValueError("message must be a non-empty string")
+
+    try:
+        # check=False: git's own stderr (e.g. a signing failure) is the most useful error text.
+        result = subprocess.run(
+            ["git", "commit", "-F", "-"],
+            input=message,
+            text=True,
+            capture_output=True,
+            check=False,
+            env={**os.environ, COMMIT_ENV_VAR: "1"},
+        )
+    except FileNotFoundError as e:
+        return CommitResult(success=False, error=str(e))
+
+    if result.returncode != 0:
+        return CommitResult(success=False, error=(result.stderr or result.stdout).strip())
+    return CommitResult(success=True, output=result.stdout.strip())
+
+
 def get_hooks_dir() -> str | None:
     if not is_git_repo():
         return None
@@ -107,6 +328,20 @@ def _backup_existing_hook(hook_path: str) -> None:
     os.rename(hook_path, hook_path + HOOK_BACKUP_SUFFIX)
 
 
+def get_hook_state(hooks_dir: str) -> str:
+    """Classify the installed hook: missing, current, stale (older noidea script) or foreign."""
+    hook_path = os.path.join(hooks_dir, HOOK_NAME)
+    if not os.path.exists(hook_path):
+        return HOOK_STATE_MISSING
+    with open(hook_path) as f:
+        content = f.read()
+    if content == HOOK_SCRIPT:
+        return HOOK_STATE_CURRENT
+    if HOOK_MARKER in content:
+        return HOOK_STATE_STALE
+    return HOOK_STATE_FOREIGN
+
+
 def install_hook() -> HookResult:
     hooks_dir = get_hooks_dir()
 
@@ -0,0 +1,119 @@
+"""Commit message checks shared by 'noidea validate' for a file and for a commit range."""
+
+import difflib
+import re
+
+SUBJECT_LENGTH_MAX = 72
+
+CONVENTIONAL_TYPES = (
+    "build",
+    "chore",
+    "ci",
+    "docs",
+    "feat",
+    "fix",
+    "perf",
+    "refactor",
+    "revert",
+    "style",
+    "test",
+)
+
+# type(scope)!: description — the same shape the default system prompt asks the model for.
+_SUBJECT_PATTERN = re.compile(r"^(?P<type>[a-z]+)(\((?P<scope>[^()\s]+)\))?!?: \S")
+
+# Git writes everything below this line in a verbose commit template; it is never committed.
+_SCISSORS_LINE = "# ------------------------ >8 ------------------------"
+
+# Messages git or the user writes by hand for housekeeping, not subject to the format.
+_EXEMPT_PREFIXES = ("Merge ", 'Revert "', "fixup! ", "squash! ", "amend! ")
+
+
+def clean_message(text: str) -> str:
+    """Strip comment lines and everything past the scissors line, as git does on commit."""
+    if not isinstance(text, str):
+        raise TypeError(f"text must be a string, got {type(text).__name__}")
+    kept = []
+    for line in text.splitlines():
+        if line == _SCISSORS_LINE:
+            break
+        if not line.startswith("#"):
+            kept.append(line.rstrip())
+    return "\n".join(kept).strip()
+
+
+def _scope_problem(scope: str, allowed_scopes: list[str]) -> str | None:
+    if scope in allowed_scopes:
+        return None
+    # A typo is the usual cause; one suggestion is more useful than the whole list.
+    closest = difflib.get_close_matches(scope, allowed_scopes, n=1)
+    if closest:
+        return f"unknown scope '{scope}' (did you mean '{closest[0]}'?)"
+    return f"unknown scope '{scope}' (use one of {', '.join(allowed_scopes)})"
+
+
+def check_message_pattern(pattern: str) -> str | None:
+    """Return what is wrong with a configured message pattern, or None when it is usable."""
+    try:
+        re.compile(pattern)
+    except re.error as error:
+        return f"is not a valid regular expression ({error})"
+    return None
+
+
+def _pattern_problem(message: str, pattern: str, example: str) -> str | None:
+    # Searched, not matched: a ticket key may sit anywhere in the subject or the body.
+    if re.search(pattern, message, re.MULTILINE):
+        return None
+    hint = f" (e.g. '{example}')" if example else ""
+    return f"message doesn't match the required pattern '{pattern}'{hint}"
+
+
+def check_message(
+    text: str,
+    allowed_scopes: list[str] | None = None,
+    pattern: str = "",
+    pattern_example: str = "",
+) -> list[str]:
+    """Return every rule the message breaks; an empty list means it passes.
+
+    With allowed_scopes, a scope must be one of them; a subject without a scope still passes.
+    With pattern, the message must also contain a match for it, on top of the format rules.
+    """
+    if allowed_scopes is not None and not isinstance(allowed_scopes, list):
+        raise TypeError(f"allowed_scopes must be a list, got {type(allowed_scopes).__name__}")
+    if pattern:
+        problem = check_message_pattern(pattern)
+        if problem is not None:
+            raise ValueError(f"pattern {problem}")
+    message = clean_message(text)
+    if not message:
+        return ["message is empty"]
+
+    lines = message.splitlines()
+    subject = lines[0]
+    if subject.startswith(_EXEMPT_PREFIXES):
+        return []
+
+    problems = []
+    if len(subject) > SUBJECT_LENGTH_MAX:
+        problems.append(f"subject is {len(subject)} characters (max {SUBJECT_LENGTH_MAX})")
+    match = _SUBJECT_PATTERN.match(subject)
+    if match is None:
+        problems.append("subject is not 'type(scope): description'")
+    elif match.group("type") not in CONVENTIONAL_TYPES:
+        problems.append(
+            f"unknown type '{match.group('type')}' (use one of {', '.join(CONVENTIONAL_TYPES)})"
+        )
+    if match is not None and match.group("scope") and allowed_scopes:
+        problem = _scope_problem(match.group("scope"), allowed_scopes)
+        if problem is not None:
+            problems.append(problem)
+    if subject.endswith("."):
+        problems.append("subject ends with a period")
+    if len(lines) > 1 and lines[1].strip():
+        problems.append("no blank line between subject and body")
+    problem = _pattern_problem(message, pattern, pattern_example) if pattern else None
+    if problem is not None:
+        problems.append(problem)
+    return problems
@@ -0,0 +1,32 @@
+"""Send policy: decides from branch and path globs whether a diff may go to the provider at all."""
+
+from fnmatch import fnmatchcase
+
+
+def _first_match(value: str, patterns: list[str]) -> str | None:
+    for pattern in patterns:
+        if fnmatchcase(value, pattern):
+            return pattern
+    return None
+
+
+def disabled_reason(
+    branch: str, paths: list[str], branch_patterns: list[str], path_patterns: list[str]
+) -> str | None:
+    """Return why AI is off for this change, or None when the diff may be sent.
+
+    Globs use fnmatch rules, where '*' also crosses '/': "security/*" covers
+    "security/cve/fix" and "secrets/*" covers every file below secrets/.
+    """
+    if not isinstance(branch, str):
+        raise TypeError(f"branch must be a string, got {type(branch).__name__}")
+
+    if branch:
+        pattern = _first_match(branch, branch_patterns)
+        if pattern is not None:
+            return f"branch '{branch}' matches llm.disable_on_branches '{pattern}'"
+    for path in paths:
+        pattern = _first_match(path, path_patterns)
+        if pattern is not None:
+            return f"'{path}' matches llm.disable_on_paths '{pattern}'"
+    return None
@@ -0,0 +1,64 @@
+"""Post-processing: pipes a generated message through a user's own command before it is used."""
+
+import shlex
+import subprocess
+from dataclasses import dataclass
+
+from noidea.secret_scan import scan_secrets
+
+# The hook runs inside 'git commit'; a stuck formatter must not hold it for long.
+POST_PROCESS_TIMEOUT_SECONDS = 5
+
+
+@dataclass
+class PostProcessResult:
+    text: str
+    # Why the command's output was not used; the text is then the message unchanged.
+    error: str = ""
+
+
+def check_post_process_command(command: str) -> str | None:
+    """Return what is wrong with a configured command, or None when it can be run."""
+    try:
+        argv = shlex.split(command)
+    except ValueError as error:
+        return f"can't be parsed ({error})"
+    if not argv:
+        return "is empty"
+    return None
+
+
+def post_process(
+    command: str, message: str, timeout_seconds: float = POST_PROCESS_TIMEOUT_SECONDS
+) -> PostProcessResult:
+    """Run command with the message on stdin and return its stdout, or the message on failure.
+
+    The command is split like a shell would but never run through one, and it only ever
+    sees the message with likely secrets redacted.
+    """
+    problem = check_post_process_command(command)
+    if problem is not None:
+        raise ValueError(f"command {problem}")
+    if not isinstance(message, str) or not message.strip():
+        raise ValueError("message must be a non-empty string")
+
+    try:
+        result = subprocess.run(
+            shlex.split(command),
+            input=scan_secrets(message).text,
+            text=True,
+            capture_output=True,
+            timeout=timeout_seconds,
+            check=False,
+        )
+    except subprocess.TimeoutExpired:
+        return PostProcessResult(text=message, error=f"timed out after {timeout_seconds:g}s")
+    # Missing, not executable, or any other reason the OS won't start it.
+    except OSError as error:
+        return PostProcessResult(text=message, error=str(error))
+    if result.returncode != 0:
+        detail = result.stderr.strip() or f"exit status {result.returncode}"
+        return PostProcessResult(text=message, error=detail)
+    if not result.stdout.strip():
+        return PostProcessResult(text=message, error="it printed nothing")
+    return PostProcessResult(text=result.stdout.strip())
@@ -1,23 +1,167 @@
 """Thin Anthropic API wrapper: key retrieval and commit message generation."""
 
+import json
 import os
+import sys
+from contextlib import contextmanager
+from datetime import datetime, timezone
 
+import anthropic
 import keyring
 from anthropic import Anthropic
 from anthropic.types import TextBlock
 from dotenv import load_dotenv
 
 from noidea.config import SERVICE_NAME, Provider
+from noidea.secret_scan import scan_secrets
 
 load_dotenv()
 
+# Upper bound on models read from the paginated list endpoint.
+MODELS_COUNT_MAX = 200
+
+API_KEY_ENV_VAR = "ANTHROPIC_API_KEY"
+
+# Sent with the diff when the caller has sized the change; the system prompt stays untouched.
+BODY_GUIDANCE = "Length: this is a large change. Include a body explaining why and what."
+SUBJECT_ONLY_GUIDANCE = "Length: this is a small change. Write only the subject line, no body."
+TEMPLATE_GUIDANCE = "Template: follow this structure for the message:"
+
+# (model ID prefix, max output tokens, context window tokens). The longest matching prefix wins,
+# so "claude-opus-4-5" overrides "claude-opus-4". Unknown models, e.g. Ollama's, are not clamped.
+_MODEL_LIMITS = (
+    ("claude-3-haiku", 4096, 200_000),
+    ("claude-3-5-haiku", 8192, 200_000),
+    ("claude-3-5-sonnet", 8192, 200_000),
+    ("claude-3-7-sonnet", 64_000, 200_000),
+    ("claude-sonnet-4", 64_000, 200_000),
+    ("claude-sonnet-4-6", 64_000, 200_000),
+    ("claude-haiku-4-5", 64_000, 200_000),
+    ("claude-opus-4", 32_000, 200_000),
+    ("claude-opus-4-5", 64_000, 200_000),
+    ("claude-opus-4-6", 128_000, 200_000),
+)
+
+# Rough size of a token in English and code; only used to warn before an oversized request.
+CHARS_PER_TOKEN_ESTIMATE = 4
+
+# Ollama ignores the key, but the SDK refuses to build a client without one.
+OLLAMA_PLACEHOLDER_KEY = "ollama"
+
+# None until a long-lived caller opts in; one-shot commands build a fresh client per call.
+_reused_clients: dict[tuple, Anthropic] | None = None
+
+
+def reuse_clients() -> None:
+    """Keep API clients, and their open connections, for the rest of the process."""
+    global _reused_clients
+    if _reused_clients is None:
+        _reused_clients = {}
+
+
+# Set by --log-requests for the rest of the process; wins over llm.request_log.
+_request_log_path: str | None = None
+
+
+def log_requests(path: str) -> None:
+    """Append every request and its response to path, as JSON lines, for this process."""
+    global _request_log_path
+    if not isinstance(path, str) or not path.strip():
+        raise ValueError("path must be a non-empty string")
+    _request_log_path = path
+
+
+def _redact_fields(fields: dict) -> dict:
+    return {
+        key: scan_secrets(value).text if isinstance(value, str) else value
+        for key, value in fields.items()
+    }
+
+
+def _log_request(path: str, request: dict, response: str | None, error: str) -> None:
+    # The log outlives the session, so it gets the same redaction as an outgoing diff. Each
+    # string is scanned before serialising: JSON escapes newlines, and a secret after an escaped
+    # "\n" no longer starts at a word boundary. The API key travels in a header, never in here.
+    messages = [_redact_fields(message) for message in request["messages"]]
+    record = _redact_fields(
+        {
+            "time": datetime.now(timezone.utc).isoformat(timespec="seconds"),
+            "request": {**_redact_fields(request), "messages": messages},
+            "response": response,
+            "error": error,
+        }
+    )
+    line = json.dumps(record)
+    try:
+        # Owner-only: the log holds diffs, which may be private code.
+        fd = os.open(path, os.O_WRONLY | os.O_CREAT | os.O_APPEND, 0o600)
+        with os.fdopen(fd, "a") as f:
+            f.write(line + "\n")
+    except OSError as log_error:
+        # Logging is a debugging aid; it must never cost the user their message.
+        print(f"Warning: could not write request log {path}: {log_error}", file=sys.stderr)
+
+
+def _client(timeout_seconds: float | None, provider: Provider, base_url: str | None) -> Anthropic:
+    api_key = get_api_key(provider)
+    cache_key = (api_key, timeout_seconds, provider, base_url)
+    if _reused_clients is not None and cache_key in _reused_clients:
+        return _reused_clients[cache_key]
+    options: dict = {"api_key": api_key}
+    if base_url is not None:
+        options["base_url"] = base_url
+    if timeout_seconds is not None:
+        # No retries: the timeout is a hard budget for the whole call, not per attempt.
+        options.update(timeout=timeout_seconds, max_retries=0)
+    client = Anthropic(**options)
+    if _reused_clients is not None:
+        _reused_clients[cache_key] = client
+    return client
+
+
+def model_limits(model: str) -> tuple[int, int] | None:
+    """Return (max output tokens, context window tokens) for a known model, else None."""
+    matches = [limits for limits in _MODEL_LIMITS if model.startswith(limits[0])]
+    if not matches:
+        return None
+    _, output_max, context_max = max(matches, key=lambda limits: len(limits[0]))
+    return output_max, context_max
+
+
+def clamp_max_tokens(model: str, max_tokens: int) -> int:
+    """Lower max_tokens to what the model can produce; asking for more is an API error."""
+    limits = model_limits(model)
+    if limits is None or max_tokens <= limits[0]:
+        return max_tokens
+    print(
+        f"Warning: llm.max_tokens {max_tokens} is over {model}'s limit, using {limits[0]}.",
+        file=sys.stderr,
+    )
+    return limits[0]
+
+
+def _warn_if_over_context(model: str, prompt_chars: int, max_tokens: int) -> None:
+    limits = model_limits(model)
+    if limits is None:
+        return
+    estimated_tokens = prompt_chars // CHARS_PER_TOKEN_ESTIMATE + max_tokens
+    if estimated_tokens > limits[1]:
+        print(
+            f"Warning: this request is roughly {estimated_tokens} tokens with the reply,"
+            f" over {model}'s {limits[1]}-token context. Try staging less.",
+            file=sys.stderr,
+        )
+
 
 def get_api_key(provider: Provider = Provider.ANTHROPIC) -> str:
     # Keyring first: credentials stay out of the process environment.
     key = keyring.get_password(service_name=SERVICE_NAME, username=provider.value)
+    # A local model needs no key; one is only stored for an Ollama behind an auth proxy.
+    if provider == Provider.OLLAMA:
+        return key or OLLAMA_PLACEHOLDER_KEY
     if not key:
         # Fall back to env var for CI and headless environments.
-        key = os.environ.get("ANTHROPIC_API_KEY")
+        key = os.environ.get(API_KEY_ENV_VAR)
     if not key:
         raise SystemExit("No API key found. Run 'noidea keys add'.")
     return key
@@ -31,6 +175,14 @@ def get_commit_message(
     branch: str = "",
     staged_files: list[str] | None = None,
     temperature: float = 1.0,
+    change_summary: str = "",
+    previous_message: str = "",
+    timeout_seconds: float | None = None,
+    wants_body: bool | None = None,
+    template: str = "",
+    provider: Provider = Provider.ANTHROPIC,
+    base_url: str | None = None,
+    request_log: str = "",
 ) -> str:
     # Validate inputs at the API boundary before spending a network round-trip.
     if not isinstance(diff, str) or not diff.strip():
@@ -43,28 +195,108 @@ def get_commit_message(
         raise TypeError(f"max_tokens must be a positive integer, got {type(max_tokens).__name__}")
     if not isinstance(temperature, (int, float)) or temperature < 0:
         raise TypeError(f"temperature must be a non-negative number, got {temperature!r}")
+    if timeout_seconds is not None and timeout_seconds <= 0:
+        raise ValueError(f"timeout_seconds must be positive, got {timeout_seconds!r}")
+
+    user_content = _user_content(
+        diff, branch, staged_files, change_summary, previous_message, wants_body, template
+    )
+    max_tokens = clamp_max_tokens(model, max_tokens)
+    _warn_if_over_context(model, len(system_prompt) + len(user_content), max_tokens)
+    request = {
+        "model": model,
+        "system": system_prompt,
+        "messages": [{"role": "user", "content": user_content}],
+        "max_tokens": max_tokens,
+        "temperature": temperature,
+    }
+    client = _client(timeout_seconds, provider, base_url)
+    log_path = _request_log_path or request_log
+    log_context = {"provider": provider.value, "base_url": base_url}
+    return _create_message(client, request, log_path, log_context)
+
 
+def _user_content(
+    diff: str,
+    branch: str,
+    staged_files: list[str] | None,
+    change_summary: str,
+    previous_message: str,
+    wants_body: bool | None,
+    template: str,
+) -> str:
+    """Put whatever context the caller has ahead of the diff."""
     context_parts = []
     if branch:
         context_parts.append(f"Branch: {branch}")
     if staged_files:
         context_parts.append("Staged files:\n" + "\n".join(f"- {f}" for f in staged_files))
+    if change_summary:
+        context_parts.append("Change summary:\n" + change_summary)
+    if previous_message:
+        context_parts.append(
+            "Existing commit message (improve this existing message while keeping its intent):\n"
+            + previous_message
+        )
+    if wants_body is not None:
+        context_parts.append(BODY_GUIDANCE if wants_body else SUBJECT_ONLY_GUIDANCE)
+    if template:
+        context_parts.append(f"{TEMPLATE_GUIDANCE}\n{template}")
 
-    user_content = ""
-    if context_parts:
-        user_content = "\n".join(context_parts) + "\n\nDiff:\n"
-    user_content += diff
-
-    client = Anthropic(api_key=get_api_key())
-    message = client.messages.create(
-        model=model,
-        system=system_prompt,
-        messages=[{"role": "user", "content": user_content}],
-        max_tokens=max_tokens,
-        temperature=temperature,
-    )
-    block = message.content[0]
-    # Claude can return tool_use or image blocks; we only handle text for commit messages.
-    if not isinstance(block, TextBlock):
-        raise TypeError(f"Expected TextBlock, got {type(block).__name__}")
-    return block.text
+    if not context_parts:
+        return diff
+    return "\n".join(context_parts) + "\n\nDiff:\n" + diff
+
+
+def _create_message(client: Anthropic, request: dict, log_path: str, log_context: dict) -> str:
+    """Send the request and return the reply, logging both to log_path when it is set."""
+    text = None
+    error_text = ""
+    try:
+        message = client.messages.create(**request)
+        block = message.content[0]
+        # Claude can return tool_use or image blocks; we only handle text for commit messages.
+        if not isinstance(block, TextBlock):
+            raise TypeError(f"Expected TextBlock, got {type(block).__name__}")
+        text = block.text
+    except Exception as error:
+        error_text = f"{type(error).__name__}: {error}"
+        raise
+    finally:
+        if log_path:
+            _log_request(log_path, {**request, **log_context}, text, error_text)
+    return text
+
+
+@contextmanager
+def reported_api_errors(timeout_message: str = ""):
+    """Print why an API call failed instead of raising; the rest of the block is skipped.
+
+    Every command reports API failures the same way. timeout_message replaces the generic
+    timeout text where the caller knows what happens next.
+    """
+    try:
+        yield
+    except anthropic.AuthenticationError as error:
+        print(f"Authentication failed. Check your API key: {error.message}")
+    except anthropic.RateLimitError as error:
+        print(f"Rate limited. Try again shortly: {error.message}")
+    except anthropic.APITimeoutError as error:
+        # Checked before APIConnectionError, its base class, to give a specific message.
+        print(timeout_message or f"Timed out waiting for the API: {error}")
+    except anthropic.APIConnectionError as error:
+        print(f"Could not connect to the API: {error}")
+    except anthropic.APIStatusError as error:
+        print(f"API error ({error.status_code}): {error.message}")
+
+
+def list_models(base_url: str | None = None) -> list[str]:
+    """Fetch the model IDs available to the configured API key."""
+    client = _client(None, Provider.ANTHROPIC, base_url)
+    models = []
+    # Iterating the page auto-paginates; the cap keeps a runaway listing bounded.
+    for model in client.models.list():
+        models.append(model.id)
+        if len(models) >= MODELS_COUNT_MAX:
+            break
+    return models
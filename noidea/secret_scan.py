@@ -0,0 +1,82 @@
+"""Pre-send secret scanner: finds likely credentials in text bound for a third party."""
+
+import math
+import re
+from dataclasses import dataclass, field
+
+SCAN_REDACT = "redact"
+SCAN_BLOCK = "block"
+SCAN_OFF = "off"
+SCAN_MODES = (SCAN_REDACT, SCAN_BLOCK, SCAN_OFF)
+
+# Known formats first: they are precise, so they win over the entropy heuristic.
+_SECRET_PATTERNS = (
+    ("private key", re.compile(r"-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----")),
+    ("aws access key", re.compile(r"\b(?:AKIA|ASIA)[0-9A-Z]{16}\b")),
+    ("github token", re.compile(r"\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})")),
+    ("anthropic key", re.compile(r"\bsk-ant-[A-Za-z0-9_-]{20,}")),
+    ("slack token", re.compile(r"\bxox[abposr]-[A-Za-z0-9-]{10,}")),
+)
+
+# Random base64-ish strings sit near 5-6 bits per character; hex digests cap at 4, so a 4.5
+# threshold skips commit hashes and lockfile checksums while catching generated secrets.
+_ENTROPY_CANDIDATE = re.compile(r"[A-Za-z0-9+/=_-]{32,}")
+_ENTROPY_BITS_PER_CHAR_MIN = 4.5
+ENTROPY_KIND = "high-entropy string"
+
+
+@dataclass
+class SecretFinding:
+    kind: str
+    line_number: int
+
+
+@dataclass
+class ScanResult:
+    text: str
+    findings: list[SecretFinding] = field(default_factory=list)
+
+
+def _entropy_bits_per_char(token: str) -> float:
+    counts: dict[str, int] = {}
+    for char in token:
+        counts[char] = counts.get(char, 0) + 1
+    total = len(token)
+    return -sum((count / total) * math.log2(count / total) for count in counts.values())
+
+
+def _redact_line(line: str, line_number: int, findings: list[SecretFinding]) -> str:
+    for kind, pattern in _SECRET_PATTERNS:
+        if pattern.search(line):
+            findings.append(SecretFinding(kind=kind, line_number=line_number))
+            line = pattern.sub(f"[REDACTED {kind}]", line)
+
+    def replace_high_entropy(match: re.Match) -> str:
+        token = match.group(0)
+        # Generated secrets almost always contain a digit; long identifiers usually don't.
+        if not any(char.isdigit() for char in token):
+            return token
+        if _entropy_bits_per_char(token) < _ENTROPY_BITS_PER_CHAR_MIN:
+            return token
+        findings.append(SecretFinding(kind=ENTROPY_KIND, line_number=line_number))
+        return f"[REDACTED {ENTROPY_KIND}]"
+
+    return _ENTROPY_CANDIDATE.sub(replace_high_entropy, line)
+
+
+def scan_secrets(text: str) -> ScanResult:
+    """Return text with likely secrets replaced, plus where each one was found."""
+    if not isinstance(text, str):
+        raise TypeError(f"text must be a string, got {type(text).__name__}")
+
+    findings: list[SecretFinding] = []
+    lines = text.split("\n")
+    redacted = [_redact_line(line, index + 1, findings) for index, line in enumerate(lines)]
+    result = ScanResult(text="\n".join(redacted), findings=findings)
+    if not findings:
+        assert result.text == text, "redaction must not alter text without findings"
+    return result
+
+
+def describe_findings(findings: list[SecretFinding]) -> str:
+    return ", ".join(f"{finding.kind} (line {finding.line_number})" for finding in findings)
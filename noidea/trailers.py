@@ -0,0 +1,168 @@
+"""Git trailer helpers: build, find and append "Key: value" lines in commit messages."""
+
+import hashlib
+import re
+import secrets
+
+SIGN_OFF_KEY = "Signed-off-by"
+CHANGE_ID_KEY = "Change-Id"
+# Config value meaning "compute this per message"; only Change-Id supports it.
+GENERATED_VALUE = "<generated>"
+
+ISSUE_TRAILER_OFF = "off"
+ISSUE_TRAILER_REFS = "refs"
+ISSUE_TRAILER_CLOSES = "closes"
+ISSUE_TRAILER_MODES = (ISSUE_TRAILER_OFF, ISSUE_TRAILER_REFS, ISSUE_TRAILER_CLOSES)
+_ISSUE_KEYWORDS = {ISSUE_TRAILER_REFS: "Refs", ISSUE_TRAILER_CLOSES: "Closes"}
+
+# GitHub's closing keywords, in any case, optionally followed by a colon: "Fixes #1",
+# "closes: #2". One keyword may cover a list, as in "Closes #3, #4 and #5".
+_CLOSING_PATTERN = re.compile(
+    r"\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+#[0-9]+(?:\s*(?:,|and)\s*#[0-9]+)*",
+    re.IGNORECASE,
+)
+_ISSUE_NUMBER_PATTERN = re.compile(r"#([0-9]+)")
+
+# Git's trailer shape: a token of letters, digits and dashes, a colon, then a value. Issue
+# references use GitHub's "Closes #42" form instead, which only links the issue without a colon.
+_TRAILER_PATTERN = re.compile(r"^[A-Za-z0-9][A-Za-z0-9-]*(: \S.*| #[0-9]+)$")
+
+# "42-fix-login", "feature/42-fix-login", "issue-42": the issue number opens a path segment.
+_BRANCH_ISSUE_PATTERN = re.compile(r"(?:^|/)(?:issue-|gh-)?([0-9]+)(?=[-_]|$)")
+
+
+def is_trailer(line: str) -> bool:
+    return bool(_TRAILER_PATTERN.match(line))
+
+
+def format_sign_off(name: str, email: str) -> str:
+    if not isinstance(name, str) or not name.strip():
+        raise ValueError("name must be a non-empty string")
+    if not isinstance(email, str) or not email.strip():
+        raise ValueError("email must be a non-empty string")
+    return f"{SIGN_OFF_KEY}: {name.strip()} <{email.strip()}>"
+
+
+def check_branch_pattern(pattern: str) -> str | None:
+    """Return what is wrong with a configured branch pattern, or None when it is usable."""
+    try:
+        compiled = re.compile(pattern)
+    except re.error as error:
+        return f"is not a valid regular expression ({error})"
+    if compiled.groups != 1:
+        return "needs exactly one capture group, around the issue number"
+    return None
+
+
+def issue_number_from_branch(branch: str, pattern: str = "") -> int | None:
+    """Find the issue number in a branch name; pattern overrides the built-in one."""
+    if pattern:
+        problem = check_branch_pattern(pattern)
+        if problem is not None:
+            raise ValueError(f"pattern {problem}")
+    match = re.search(pattern, branch) if pattern else _BRANCH_ISSUE_PATTERN.search(branch)
+    # A configured group may be optional and match nothing, or capture more than digits.
+    if match is None or match.group(1) is None or not match.group(1).isdigit():
+        return None
+    number = int(match.group(1))
+    return number if number > 0 else None
+
+
+def issue_trailer_for_branch(mode: str, branch: str, pattern: str = "") -> str | None:
+    """Return e.g. "Refs #42" for branch "42-foo", or None when off or no number is found."""
+    if mode not in ISSUE_TRAILER_MODES:
+        raise ValueError(f"mode must be one of {', '.join(ISSUE_TRAILER_MODES)}, got {mode!r}")
+    if mode == ISSUE_TRAILER_OFF:
+        return None
+    number = issue_number_from_branch(branch, pattern)
+    if number is None:
+        return None
+    return f"{_ISSUE_KEYWORDS[mode]} #{number}"
+
+
+def closing_references(message: str) -> list[int]:
+    """Return the issue numbers a message closes on GitHub, in order of first mention."""
+    if not isinstance(message, str):
+        raise TypeError(f"message must be a string, got {type(message).__name__}")
+    numbers: list[int] = []
+    for match in _CLOSING_PATTERN.finditer(message):
+        for number in _ISSUE_NUMBER_PATTERN.findall(match.group(0)):
+            if int(number) > 0 and int(number) not in numbers:
+                numbers.append(int(number))
+    return numbers
+
+
+def generate_change_id(message: str, nonce: str | None = None) -> str:
+    """Return a Gerrit-style Change-Id: "I" and 40 hex digits, new on every call by default."""
+    if nonce is None:
+        nonce = secrets.token_hex(16)
+    digest = hashlib.sha1(f"{message}\n{nonce}".encode()).hexdigest()
+    return f"I{digest}"
+
+
+def check_configured_trailer(template: str) -> str | None:
+    """Return what is wrong with a configured trailer, or None when it is usable."""
+    if not isinstance(template, str) or not is_trailer(template):
+        return f"not a 'Key: value' trailer: {template!r}"
+    key, _, value = template.partition(": ")
+    if value == GENERATED_VALUE and key != CHANGE_ID_KEY:
+        return f"only {CHANGE_ID_KEY} can be {GENERATED_VALUE}, got {template!r}"
+    return None
+
+
+def resolve_configured_trailers(
+    templates: list[str], message: str, existing: list[str]
+) -> list[str]:
+    """Fill in generated values; an existing Change-Id wins so amends keep their Gerrit change."""
+    has_change_id = bool(find_trailers(message, CHANGE_ID_KEY)) or bool(
+        find_trailers("\n".join(existing), CHANGE_ID_KEY)
+    )
+    resolved = []
+    for template in templates:
+        problem = check_configured_trailer(template)
+        if problem is not None:
+            raise ValueError(problem)
+        if not template.endswith(f": {GENERATED_VALUE}"):
+            resolved.append(template)
+        elif not has_change_id:
+            resolved.append(f"{CHANGE_ID_KEY}: {generate_change_id(message)}")
+    return resolved
+
+
+def find_trailers(text: str, key: str) -> list[str]:
+    """Return the non-comment lines of text that are trailers with the given key."""
+    if not isinstance(key, str) or not key:
+        raise ValueError("key must be a non-empty string")
+    prefix = f"{key}: "
+    # Trailers start at column 0; an indented match is diff context or quoted text.
+    return [
+        line.rstrip()
+        for line in text.splitlines()
+        if not line.startswith("#") and line.startswith(prefix)
+    ]
+
+
+def append_trailers(message: str, trailers: list[str]) -> str:
+    """Append trailers as the message's final paragraph, skipping ones already present."""
+    if not isinstance(message, str) or not message.strip():
+        raise ValueError("message must be a non-empty string")
+    for trailer in trailers:
+        if not is_trailer(trailer):
+            raise ValueError(f"not a 'Key: value' trailer: {trailer!r}")
+
+    message = message.rstrip()
+    present = {line.strip() for line in message.splitlines()}
+    missing = []
+    for trailer in trailers:
+        if trailer not in present and trailer not in missing:
+            missing.append(trailer)
+    if not missing:
+        return message
+
+    # Extend an existing trailer block rather than starting a second one. The subject line
+    # never counts: "fix: thing" has the shape of a trailer but is not one.
+    paragraphs = message.split("\n\n")
+    last_paragraph = paragraphs[-1].splitlines()
+    ends_in_trailers = len(paragraphs) > 1 and all(is_trailer(line) for line in last_paragraph)
+    separator = "\n" if ends_in_trailers else "\n\n"
+    return message + separator + "\n".join(missing)
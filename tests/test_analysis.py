@@ -0,0 +1,216 @@
+import pytest
+
+from noidea.analysis import (
+    CATEGORY_CI,
+    CATEGORY_CODE,
+    CATEGORY_CONFIG,
+    CATEGORY_DOCS,
+    CATEGORY_TESTS,
+    CHANGE_TYPE_MIXED,
+    OTHER_AREA,
+    ROOT_AREA,
+    STATUS_ADDED,
+    STATUS_DELETED,
+    STATUS_MODIFIED,
+    STATUS_RENAMED,
+    analyze_diff,
+    area_of,
+    categorize_path,
+    change_type,
+    format_analysis,
+    group_diff_by_area,
+    split_diff_by_file,
+    template_for_change,
+    wants_body,
+)
+
+SAMPLE_DIFF = """diff --git a/noidea/git.py b/noidea/git.py
+index 1111111..2222222 100644
+--- a/noidea/git.py
++++ b/noidea/git.py
+@@ -1,3 +1,4 @@
+ import os
++import sys
+-import re
++import json
+diff --git a/docs/guide.md b/docs/guide.md
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/docs/guide.md
+@@ -0,0 +1,2 @@
++# Guide
++++ not a header, just content
+diff --git a/tests/test_old.py b/tests/test_old.py
+deleted file mode 100644
+index 4444444..0000000
+--- a/tests/test_old.py
++++ /dev/null
+@@ -1 +0,0 @@
+-def test_old(): pass
+diff --git a/old_name.py b/new_name.py
+similarity index 100%
+rename from old_name.py
+rename to new_name.py
+"""
+
+
+class TestCategorizePath:
+    def test_source_file_is_code(self):
+        assert categorize_path("noidea/git.py") == CATEGORY_CODE
+
+    def test_markdown_is_docs(self):
+        assert categorize_path("README.md") == CATEGORY_DOCS
+
+    def test_docs_directory_is_docs(self):
+        assert categorize_path("docs/conf.py") == CATEGORY_DOCS
+
+    def test_tests_directory_is_tests(self):
+        assert categorize_path("tests/test_git.py") == CATEGORY_TESTS
+
+    def test_workflow_is_ci(self):
+        assert categorize_path(".github/workflows/test.yml") == CATEGORY_CI
+
+    def test_toml_is_config(self):
+        assert categorize_path("pyproject.toml") == CATEGORY_CONFIG
+
+    def test_rejects_empty_path(self):
+        with pytest.raises(ValueError, match="path"):
+            categorize_path("")
+
+
+class TestAnalyzeDiff:
+    def test_counts_each_status(self):
+        analysis = analyze_diff(SAMPLE_DIFF)
+        assert len(analysis.files) == 4
+        assert analysis.count_status(STATUS_MODIFIED) == 1
+        assert analysis.count_status(STATUS_ADDED) == 1
+        assert analysis.count_status(STATUS_DELETED) == 1
+        assert analysis.count_status(STATUS_RENAMED) == 1
+
+    def test_counts_lines_inside_hunks_only(self):
+        analysis = analyze_diff(SAMPLE_DIFF)
+        modified = analysis.files[0]
+        assert modified.lines_added == 2
+        assert modified.lines_removed == 1
+        # A content line starting with "++" is still an added line, not a file header.
+        assert analysis.files[1].lines_added == 2
+        assert analysis.lines_added == 4
+        assert analysis.lines_removed == 2
+
+    def test_rename_reports_new_path(self):
+        analysis = analyze_diff(SAMPLE_DIFF)
+        assert analysis.files[3].path == "new_name.py"
+
+    def test_assigns_categories(self):
+        analysis = analyze_diff(SAMPLE_DIFF)
+        assert analysis.count_categories() == {
+            CATEGORY_CODE: 2,
+            CATEGORY_DOCS: 1,
+            CATEGORY_TESTS: 1,
+        }
+
+    def test_empty_diff_has_no_files(self):
+        assert analyze_diff("").files == []
+
+    def test_rejects_non_string(self):
+        with pytest.raises(TypeError, match="diff"):
+            analyze_diff(None)
+
+
+class TestFormatAnalysis:
+    def test_summary_lines(self):
+        text = format_analysis(analyze_diff(SAMPLE_DIFF))
+        assert "Files changed: 4 (1 added, 1 modified, 1 deleted, 1 renamed)" in text
+        assert "Lines: +4 -2" in text
+        assert "Categories: code 2, docs 1, tests 1" in text
+
+    def test_empty_analysis(self):
+        text = format_analysis(analyze_diff(""))
+        assert "Files changed: 0" in text
+        assert "Categories: none" in text
+
+
+class TestGroupDiffByArea:
+    def test_area_is_top_level_directory(self):
+        assert area_of("noidea/commands/suggest.py") == "noidea"
+        assert area_of("README.md") == ROOT_AREA
+
+    def test_split_keeps_each_file_whole(self):
+        chunks = split_diff_by_file(SAMPLE_DIFF)
+        assert [path for path, _ in chunks] == [
+            "noidea/git.py",
+            "docs/guide.md",
+            "tests/test_old.py",
+            "new_name.py",
+        ]
+        assert chunks[0][1].startswith("diff --git a/noidea/git.py")
+        assert "+import json" in chunks[0][1]
+        assert "docs/guide.md" not in chunks[0][1]
+
+    def test_groups_by_area_largest_first(self):
+        diff = SAMPLE_DIFF + "\ndiff --git a/docs/api.md b/docs/api.md\n+more"
+        groups = group_diff_by_area(diff, groups_max=5)
+        assert [group.area for group in groups] == ["docs", "noidea", "tests", ROOT_AREA]
+        assert groups[0].paths == ["docs/guide.md", "docs/api.md"]
+        assert "+more" in groups[0].diff
+
+    def test_cap_folds_smallest_areas_together(self):
+        groups = group_diff_by_area(SAMPLE_DIFF, groups_max=2)
+        assert [group.area for group in groups] == ["noidea", OTHER_AREA]
+        assert groups[1].paths == ["docs/guide.md", "tests/test_old.py", "new_name.py"]
+
+    def test_rejects_zero_cap(self):
+        with pytest.raises(ValueError, match="groups_max"):
+            group_diff_by_area(SAMPLE_DIFF, groups_max=0)
+
+
+class TestWantsBody:
+    def test_small_diff_is_subject_only(self):
+        assert wants_body(analyze_diff(SAMPLE_DIFF), files_min=5, lines_min=150) is False
+
+    def test_many_files_wants_body(self):
+        assert wants_body(analyze_diff(SAMPLE_DIFF), files_min=4, lines_min=150) is True
+
+    def test_many_lines_wants_body(self):
+        assert wants_body(analyze_diff(SAMPLE_DIFF), files_min=5, lines_min=6) is True
+
+    def test_zero_threshold_is_ignored(self):
+        assert wants_body(analyze_diff(SAMPLE_DIFF), files_min=0, lines_min=6) is True
+        assert wants_body(analyze_diff(SAMPLE_DIFF), files_min=0, lines_min=100) is False
+
+    def test_both_zero_leaves_it_to_the_prompt(self):
+        assert wants_body(analyze_diff(SAMPLE_DIFF), files_min=0, lines_min=0) is None
+
+    def test_rejects_negative_threshold(self):
+        with pytest.raises(ValueError, match="thresholds"):
+            wants_body(analyze_diff(SAMPLE_DIFF), files_min=-1, lines_min=0)
+
+
+class TestTemplateForChange:
+    _DOCS_DIFF = (
+        "diff --git a/README.md b/README.md\n+intro\ndiff --git a/docs/x.rst b/docs/x.rst\n+y"
+    )
+    _TEMPLATES = {"docs": "docs: <what changed>", "default": "type(scope): summary\n\nwhy"}
+
+    def test_docs_only_diff_is_docs(self):
+        assert change_type(analyze_diff(self._DOCS_DIFF)) == CATEGORY_DOCS
+
+    def test_mixed_categories(self):
+        assert change_type(analyze_diff(SAMPLE_DIFF)) == CHANGE_TYPE_MIXED
+
+    def test_empty_diff_has_no_type(self):
+        assert change_type(analyze_diff("")) is None
+
+    def test_docs_only_diff_selects_docs_template(self):
+        assert template_for_change(analyze_diff(self._DOCS_DIFF), self._TEMPLATES) == (
+            "docs: <what changed>"
+        )
+
+    def test_falls_back_to_default_template(self):
+        assert template_for_change(analyze_diff(SAMPLE_DIFF), self._TEMPLATES) == (
+            "type(scope): summary\n\nwhy"
+        )
+
+    def test_no_templates_means_none(self):
+        assert template_for_change(analyze_diff(self._DOCS_DIFF), {}) == ""
@@ -1,15 +1,24 @@
 import json
+from datetime import datetime, timedelta, timezone
 from unittest.mock import patch
 
 from noidea.config import (
     DEFAULTS,
+    check_base_url,
     deep_merge,
+    forget_key_date,
+    get_key_age_days,
+    get_system_config_path,
     initialize,
     list_keys,
     load_config,
+    provider_base_url,
+    read_models_cache,
+    record_key_date,
     remove_key,
     save_key,
     validate_config,
+    write_models_cache,
 )
 
 
@@ -64,6 +73,19 @@ def test_load_config_returns_defaults_after_initialize(tmp_path):
     assert result["llm"]["max_tokens"] == 1024
 
 
+def test_system_config_survives_initialize(tmp_path):
+    system_config = tmp_path / "etc" / "config.json"
+    system_config.parent.mkdir()
+    system_config.write_text(json.dumps({"llm": {"max_tokens": 64}}))
+    p1, p2, p3 = _patch_paths(tmp_path)
+    with p1, p2, p3, _patch_no_repo():
+        with patch.dict("os.environ", {"NOIDEA_SYSTEM_CONFIG": str(system_config)}):
+            initialize()
+            result = load_config()
+
+    assert result["llm"]["max_tokens"] == 64
+
+
 def test_load_config_user_overrides_defaults(tmp_path):
     config_file = tmp_path / "config.json"
     config_file.write_text(json.dumps({"llm": {"max_tokens": 512}}))
@@ -117,6 +139,62 @@ def test_load_config_repo_partial_override(tmp_path):
     assert result["llm"]["small_model"] == "claude-haiku-4-5"
 
 
+class TestSystemConfig:
+    def _write(self, path, llm):
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(json.dumps({"llm": llm}))
+
+    def test_precedence_system_user_repo(self, tmp_path):
+        system_config = tmp_path / "etc" / "config.json"
+        self._write(system_config, {"max_tokens": 100, "temperature": 0.1, "small_model": "sys"})
+        user_config = tmp_path / "user" / "config.json"
+        self._write(user_config, {"max_tokens": 200, "temperature": 0.2})
+        repo_root = tmp_path / "repo"
+        self._write(repo_root / ".noidea" / "config.json", {"max_tokens": 300})
+
+        with (
+            patch("noidea.config.SYSTEM_CONFIG_PATH", str(system_config)),
+            patch.dict("os.environ", {"NOIDEA_SYSTEM_CONFIG": ""}),
+            patch("noidea.config.CONFIG_PATH", str(user_config)),
+            patch("noidea.config.get_git_root", return_value=str(repo_root)),
+        ):
+            result = load_config()
+
+        assert result["llm"]["max_tokens"] == 300
+        assert result["llm"]["temperature"] == 0.2
+        assert result["llm"]["small_model"] == "sys"
+        assert result["llm"]["large_model"] == "claude-sonnet-4-6"
+
+    def test_env_var_overrides_system_path(self, tmp_path):
+        custom = tmp_path / "team.json"
+        self._write(custom, {"max_tokens": 64})
+
+        with (
+            patch("noidea.config.SYSTEM_CONFIG_PATH", str(tmp_path / "missing.json")),
+            patch.dict("os.environ", {"NOIDEA_SYSTEM_CONFIG": str(custom)}),
+            patch("noidea.config.CONFIG_PATH", str(tmp_path / "nonexistent.json")),
+            _patch_no_repo(),
+        ):
+            assert get_system_config_path() == str(custom)
+            result = load_config()
+
+        assert result["llm"]["max_tokens"] == 64
+
+    def test_corrupted_system_config_is_skipped(self, tmp_path):
+        system_config = tmp_path / "config.json"
+        system_config.write_text("{broken")
+
+        with (
+            patch("noidea.config.SYSTEM_CONFIG_PATH", str(system_config)),
+            patch.dict("os.environ", {"NOIDEA_SYSTEM_CONFIG": ""}),
+            patch("noidea.config.CONFIG_PATH", str(tmp_path / "nonexistent.json")),
+            _patch_no_repo(),
+        ):
+            result = load_config()
+
+        assert result["llm"]["max_tokens"] == 1024
+
+
 def test_load_config_defaults_have_all_expected_keys(tmp_path):
     p1, p2, p3 = _patch_paths(tmp_path)
     with p1, p2, p3, _patch_no_repo():
@@ -189,6 +267,57 @@ class TestListKeys:
         assert result == []
 
 
+class TestKeyDates:
+    NOW = datetime(2026, 6, 1, tzinfo=timezone.utc)
+
+    def test_age_counts_days_since_recorded(self, tmp_path):
+        with patch("noidea.config.KEY_DATES_PATH", str(tmp_path / "key_dates.json")):
+            record_key_date("anthropic", now=self.NOW - timedelta(days=100))
+            assert get_key_age_days("anthropic", now=self.NOW) == 100
+
+    def test_re_adding_restarts_the_age(self, tmp_path):
+        with patch("noidea.config.KEY_DATES_PATH", str(tmp_path / "key_dates.json")):
+            record_key_date("anthropic", now=self.NOW - timedelta(days=100))
+            record_key_date("anthropic", now=self.NOW)
+            assert get_key_age_days("anthropic", now=self.NOW) == 0
+
+    def test_unknown_key_has_no_age(self, tmp_path):
+        with patch("noidea.config.KEY_DATES_PATH", str(tmp_path / "key_dates.json")):
+            assert get_key_age_days("anthropic", now=self.NOW) is None
+
+    def test_forget_removes_date(self, tmp_path):
+        with patch("noidea.config.KEY_DATES_PATH", str(tmp_path / "key_dates.json")):
+            record_key_date("anthropic", now=self.NOW)
+            forget_key_date("anthropic")
+            assert get_key_age_days("anthropic", now=self.NOW) is None
+
+
+class TestModelsCache:
+    NOW = datetime(2026, 6, 1, tzinfo=timezone.utc)
+
+    def test_fresh_cache_is_returned(self, tmp_path):
+        with patch("noidea.config.MODELS_CACHE_PATH", str(tmp_path / "models_cache.json")):
+            write_models_cache(["claude-haiku-4-5"], "", now=self.NOW)
+            cached = read_models_cache("", now=self.NOW + timedelta(minutes=5))
+        assert cached == ["claude-haiku-4-5"]
+
+    def test_stale_cache_is_ignored(self, tmp_path):
+        with patch("noidea.config.MODELS_CACHE_PATH", str(tmp_path / "models_cache.json")):
+            write_models_cache(["claude-haiku-4-5"], "", now=self.NOW)
+            cached = read_models_cache("", now=self.NOW + timedelta(hours=2))
+        assert cached is None
+
+    def test_missing_cache_returns_none(self, tmp_path):
+        with patch("noidea.config.MODELS_CACHE_PATH", str(tmp_path / "models_cache.json")):
+            assert read_models_cache("") is None
+
+    def test_cache_from_another_endpoint_is_ignored(self, tmp_path):
+        with patch("noidea.config.MODELS_CACHE_PATH", str(tmp_path / "models_cache.json")):
+            write_models_cache(["claude-haiku-4-5"], "", now=self.NOW)
+            cached = read_models_cache("https://gw.example.com", now=self.NOW)
+        assert cached is None
+
+
 class TestValidateConfig:
     def test_valid_config_passes_through(self):
         result = validate_config({"llm": {**DEFAULTS["llm"]}})
@@ -215,6 +344,86 @@ class TestValidateConfig:
         result = validate_config(config)
         assert result["llm"]["context_limit"] == 500000.0
 
+    def test_invalid_message_pattern_falls_back_to_default(self):
+        config = {"llm": {**DEFAULTS["llm"], "message_pattern": "([A-Z]+"}}
+        result = validate_config(config)
+        assert result["llm"]["message_pattern"] == ""
+
+    def test_unknown_secret_scan_mode_falls_back_to_default(self):
+        config = {"llm": {**DEFAULTS["llm"], "secret_scan": "yolo"}}
+        result = validate_config(config)
+        assert result["llm"]["secret_scan"] == "redact"
+
+    def test_non_string_glob_list_falls_back_to_default(self):
+        config = {"llm": {**DEFAULTS["llm"], "disable_on_branches": ["security/*", 3]}}
+        result = validate_config(config)
+        assert result["llm"]["disable_on_branches"] == []
+
+    def test_malformed_trailer_falls_back_to_default(self):
+        config = {"llm": {**DEFAULTS["llm"], "trailers": ["Reviewed-by: Bo", "no colon here"]}}
+        result = validate_config(config)
+        assert result["llm"]["trailers"] == []
+
+    def test_unknown_template_type_falls_back_to_default(self):
+        config = {"llm": {**DEFAULTS["llm"], "templates": {"docs": "docs: x", "fixes": "fix: x"}}}
+        result = validate_config(config)
+        assert result["llm"]["templates"] == {}
+
+    def test_empty_template_falls_back_to_default(self):
+        config = {"llm": {**DEFAULTS["llm"], "templates": {"docs": "  "}}}
+        result = validate_config(config)
+        assert result["llm"]["templates"] == {}
+
+    def test_unknown_provider_falls_back_to_default(self):
+        config = {"llm": {**DEFAULTS["llm"], "provider": "openai"}}
+        result = validate_config(config)
+        assert result["llm"]["provider"] == "anthropic"
+
+    def test_invalid_issue_branch_pattern_falls_back_to_default(self):
+        config = {"llm": {**DEFAULTS["llm"], "issue_branch_pattern": "([0-9]+"}}
+        result = validate_config(config)
+        assert result["llm"]["issue_branch_pattern"] == ""
+
+    def test_non_positive_hook_timeout_falls_back_to_default(self):
+        for timeout in (0, -3.5):
+            config = {"llm": {**DEFAULTS["llm"], "hook_timeout_seconds": timeout}}
+            result = validate_config(config)
+            assert result["llm"]["hook_timeout_seconds"] == 8
+
+    def test_negative_body_thresholds_fall_back_to_default(self):
+        config = {"llm": {**DEFAULTS["llm"], "body_min_files": -1, "body_min_lines": -10}}
+        result = validate_config(config)
+        assert result["llm"]["body_min_files"] == 5
+        assert result["llm"]["body_min_lines"] == 150
+
+    def test_zero_body_threshold_is_kept(self):
+        config = {"llm": {**DEFAULTS["llm"], "body_min_files": 0}}
+        assert validate_config(config)["llm"]["body_min_files"] == 0
+
+    def test_non_positive_range_commits_max_falls_back_to_default(self):
+        config = {"llm": {**DEFAULTS["llm"], "range_commits_max": 0}}
+        result = validate_config(config)
+        assert result["llm"]["range_commits_max"] == 500
+
+    def test_https_base_url_is_kept(self):
+        config = {"llm": {**DEFAULTS["llm"], "base_url": "https://llm-proxy.corp.example"}}
+        result = validate_config(config)
+        assert provider_base_url(result) == "https://llm-proxy.corp.example"
+
+    def test_plain_http_base_url_falls_back_to_default(self):
+        config = {"llm": {**DEFAULTS["llm"], "base_url": "http://llm-proxy.corp.example"}}
+        result = validate_config(config)
+        assert result["llm"]["base_url"] == ""
+        assert provider_base_url(result) is None
+
+    def test_loopback_http_base_url_is_allowed(self):
+        assert check_base_url("http://localhost:8080") is None
+        assert check_base_url("http://127.0.0.1:8080/v1") is None
+
+    def test_malformed_base_url(self):
+        assert "well-formed" in check_base_url("api.example.com")
+        assert "well-formed" in check_base_url("ftp://api.example.com")
+
 
 class TestLoadConfigErrors:
     def test_corrupted_json_falls_back_to_defaults(self, tmp_path):
@@ -1,7 +1,30 @@
 import os
+import subprocess
 from unittest.mock import MagicMock, patch
 
-from noidea.git import get_diff, get_hooks_dir, install_hook
+import pytest
+
+from noidea.git import (
+    EMPTY_TREE_HASH,
+    HOOK_NAME,
+    HOOK_SCRIPT,
+    HOOK_STATE_CURRENT,
+    HOOK_STATE_FOREIGN,
+    HOOK_STATE_MISSING,
+    HOOK_STATE_STALE,
+    RANGE_COMMITS_MAX,
+    commit_with_message,
+    get_amend_diff,
+    get_commit_diff,
+    get_commit_messages,
+    get_diff,
+    get_git_identity,
+    get_head_message,
+    get_hook_state,
+    get_hooks_dir,
+    get_range_diff,
+    install_hook,
+)
 
 
 def test_get_diff_nothing_staged():
@@ -60,7 +83,7 @@ def test_install_hook(tmp_path):
     hook_path = tmp_path / "prepare-commit-msg"
 
     assert hook_path.exists()
-    assert hook_path.read_text() == '#!/bin/bash\nnoidea suggest --file "$1"\n'
+    assert hook_path.read_text() == HOOK_SCRIPT
     assert os.access(hook_path, os.X_OK)
 
 
@@ -74,7 +97,7 @@ def test_install_hook_backs_up_existing(tmp_path):
     backup_path = tmp_path / "prepare-commit-msg.bak"
     assert backup_path.exists()
     assert backup_path.read_text() == "#!/bin/bash\necho old hook\n"
-    assert hook_path.read_text() == '#!/bin/bash\nnoidea suggest --file "$1"\n'
+    assert hook_path.read_text() == HOOK_SCRIPT
 
 
 def test_install_hook_empty_hooks_dir():
@@ -122,3 +145,249 @@ def test_install_hook_makedirs_failure():
         result = install_hook()
     assert not result.success
     assert "Permission denied" in result.error
+
+
+def test_hook_script_skips_supplied_messages():
+    # -m/-F commits (including 'noidea commit') pass source "message"; the hook must not overwrite.
+    assert '"$2" = "message"' in HOOK_SCRIPT
+    assert 'noidea suggest --file "$1"' in HOOK_SCRIPT
+
+
+def test_commit_with_message_pipes_message_to_git():
+    mock_result = MagicMock(returncode=0, stdout="[main abc123] feat: thing\n", stderr="")
+
+    with patch("noidea.git.subprocess.run", return_value=mock_result) as mock_run:
+        result = commit_with_message("feat: thing")
+
+    assert result.success
+    assert result.output == "[main abc123] feat: thing"
+    args, kwargs = mock_run.call_args
+    assert args[0] == ["git", "commit", "-F", "-"]
+    assert kwargs["input"] == "feat: thing"
+
+
+def test_commit_with_message_tells_stale_hooks_to_keep_the_message():
+    mock_result = MagicMock(returncode=0, stdout="", stderr="")
+
+    with patch("noidea.git.subprocess.run", return_value=mock_result) as mock_run:
+        commit_with_message("feat: thing")
+
+    assert mock_run.call_args.kwargs["env"]["NOIDEA_COMMIT"] == "1"
+
+
+def test_commit_with_message_surfaces_git_error():
+    mock_result = MagicMock(
+        returncode=128, stdout="", stderr="error: gpg failed to sign the data\n"
+    )
+
+    with patch("noidea.git.subprocess.run", return_value=mock_result):
+        result = commit_with_message("feat: thing")
+
+    assert not result.success
+    assert "gpg failed to sign" in result.error
+
+
+def test_commit_with_message_rejects_empty_message():
+    with pytest.raises(ValueError, match="message"):
+        commit_with_message("  ")
+
+
+def test_get_amend_diff_uses_head_parent():
+    parent = MagicMock(returncode=0, stdout="abc123\n")
+    diff = MagicMock(stdout="+ amended change")
+
+    with patch("noidea.git.subprocess.run", side_effect=[parent, diff]) as mock_run:
+        result = get_amend_diff()
+
+    assert result.has_changes
+    assert mock_run.call_args_list[1].args[0] == ["git", "diff", "--staged", "abc123"]
+
+
+def test_get_amend_diff_root_commit_uses_empty_tree():
+    parent = MagicMock(returncode=1, stdout="")
+    diff = MagicMock(stdout="+ first file")
+
+    with patch("noidea.git.subprocess.run", side_effect=[parent, diff]) as mock_run:
+        get_amend_diff()
+
+    assert mock_run.call_args_list[1].args[0] == ["git", "diff", "--staged", EMPTY_TREE_HASH]
+
+
+def test_get_head_message_without_commits():
+    mock_result = MagicMock(returncode=128, stdout="")
+
+    with patch("noidea.git.subprocess.run", return_value=mock_result):
+        assert get_head_message() == ""
+
+
+def test_get_git_identity_reads_name_and_email():
+    name = MagicMock(returncode=0, stdout="Ada Lovelace\n")
+    email = MagicMock(returncode=0, stdout="ada@example.com\n")
+
+    with patch("noidea.git.subprocess.run", side_effect=[name, email]):
+        assert get_git_identity() == ("Ada Lovelace", "ada@example.com")
+
+
+def test_get_git_identity_unset_email_is_empty():
+    name = MagicMock(returncode=0, stdout="Ada Lovelace\n")
+    email = MagicMock(returncode=1, stdout="")
+
+    with patch("noidea.git.subprocess.run", side_effect=[name, email]):
+        assert get_git_identity() == ("Ada Lovelace", "")
+
+
+def _log_output(*commits):
+    return "".join(f"{sha}\x1f{message}\n\x1e\n" for sha, message in commits)
+
+
+def test_get_commit_messages_parses_multi_line_messages():
+    stdout = _log_output(("a" * 40, "feat: one\n\nbody line"), ("b" * 40, "fix bug"))
+    mock_result = MagicMock(returncode=0, stdout=stdout, stderr="")
+
+    with patch("noidea.git.subprocess.run", return_value=mock_result) as mock_run:
+        result = get_commit_messages("origin/main..HEAD")
+
+    assert result.success and not result.truncated
+    assert [commit.sha for commit in result.commits] == ["a" * 40, "b" * 40]
+    assert result.commits[0].message == "feat: one\n\nbody line"
+    assert "origin/main..HEAD" in mock_run.call_args.args[0]
+
+
+def test_get_commit_messages_flags_truncated_range():
+    commits = [(f"{index:040d}", "chore: x") for index in range(RANGE_COMMITS_MAX + 1)]
+    mock_result = MagicMock(returncode=0, stdout=_log_output(*commits), stderr="")
+
+    count_result = MagicMock(returncode=0, stdout="12345\n", stderr="")
+
+    with patch("noidea.git.subprocess.run", side_effect=[mock_result, count_result]) as mock_run:
+        result = get_commit_messages("main")
+
+    assert result.truncated
+    assert len(result.commits) == RANGE_COMMITS_MAX
+    assert result.total == 12345
+    assert mock_run.call_args.args[0] == ["git", "rev-list", "--count", "main", "--"]
+
+
+def test_get_commit_messages_honours_a_smaller_cap():
+    commits = [(f"{index:040d}", "chore: x") for index in range(4)]
+    mock_result = MagicMock(returncode=0, stdout=_log_output(*commits[:3]), stderr="")
+    count_result = MagicMock(returncode=0, stdout="4\n", stderr="")
+
+    with patch("noidea.git.subprocess.run", side_effect=[mock_result, count_result]) as mock_run:
+        result = get_commit_messages("main", commits_max=2)
+
+    assert "--max-count=3" in mock_run.call_args_list[0].args[0]
+    assert [commit.sha for commit in result.commits] == [commits[0][0], commits[1][0]]
+    assert result.truncated and result.total == 4
+
+
+def test_get_commit_messages_rejects_a_non_positive_cap():
+    with pytest.raises(ValueError):
+        get_commit_messages("main", commits_max=0)
+
+
+def test_get_commit_messages_unknown_revision():
+    mock_result = MagicMock(returncode=128, stdout="", stderr="fatal: bad revision 'nope'\n")
+
+    with patch("noidea.git.subprocess.run", return_value=mock_result):
+        result = get_commit_messages("nope")
+
+    assert not result.success
+    assert "bad revision" in result.error
+
+
+def test_get_range_diff_uses_merge_base():
+    mock_result = MagicMock(returncode=0, stdout="+ change\n", stderr="")
+
+    with patch("noidea.git.subprocess.run", return_value=mock_result) as mock_run:
+        result = get_range_diff("origin/main..feature")
+
+    assert result.has_changes and result.diff == "+ change\n"
+    assert mock_run.call_args.args[0] == ["git", "diff", "origin/main...feature", "--"]
+
+
+def test_get_range_diff_defaults_head():
+    mock_result = MagicMock(returncode=0, stdout="", stderr="")
+
+    with patch("noidea.git.subprocess.run", return_value=mock_result) as mock_run:
+        result = get_range_diff("main..")
+
+    assert not result.has_changes
+    assert mock_run.call_args.args[0][2] == "main...HEAD"
+
+
+def test_get_range_diff_rejects_non_range():
+    for rev_range in ("main", "..HEAD", "main...HEAD"):
+        with pytest.raises(ValueError, match="BASE..HEAD"):
+            get_range_diff(rev_range)
+
+
+def test_get_commit_diff_shows_one_commit():
+    mock_result = MagicMock(returncode=0, stdout="diff --git a/x b/x\n+y\n", stderr="")
+
+    with patch("noidea.git.subprocess.run", return_value=mock_result) as mock_run:
+        result = get_commit_diff("abc123")
+
+    assert result.has_changes and "+y" in result.diff
+    assert mock_run.call_args.args[0] == [
+        "git", "show", "--format=", "--patch", "--diff-merges=first-parent", "abc123", "--"
+    ]
+
+
+def test_get_commit_diff_of_a_merge_lists_the_merged_paths(tmp_path, monkeypatch):
+    monkeypatch.chdir(tmp_path)
+    for key in ("AUTHOR", "COMMITTER"):
+        monkeypatch.setenv(f"GIT_{key}_NAME", "Ada")
+        monkeypatch.setenv(f"GIT_{key}_EMAIL", "ada@example.com")
+
+    def git(*args):
+        subprocess.run(["git", *args], check=True, capture_output=True)
+
+    git("init", "-q", "-b", "main")
+    (tmp_path / "README").write_text("hi\n")
+    git("add", "README")
+    git("commit", "-q", "-m", "init")
+    git("checkout", "-q", "-b", "topic")
+    (tmp_path / "secrets").mkdir()
+    (tmp_path / "secrets" / "key.pem").write_text("pem\n")
+    git("add", "secrets")
+    git("commit", "-q", "-m", "add key")
+    git("checkout", "-q", "main")
+    (tmp_path / "README").write_text("hello\n")
+    git("commit", "-q", "-am", "edit readme")
+    git("merge", "-q", "--no-edit", "topic")
+
+    result = get_commit_diff("HEAD")
+
+    # A combined "diff --cc" would hide the path from llm.disable_on_paths.
+    assert "diff --git a/secrets/key.pem b/secrets/key.pem" in result.diff
+    assert "diff --cc" not in result.diff
+
+
+def test_get_commit_diff_unknown_revision():
+    mock_result = MagicMock(returncode=128, stdout="", stderr="fatal: bad object nope\n")
+
+    with patch("noidea.git.subprocess.run", return_value=mock_result):
+        result = get_commit_diff("nope")
+
+    assert not result.has_changes
+    assert result.error == "fatal: bad object nope"
+
+
+def test_get_hook_state_missing(tmp_path):
+    assert get_hook_state(str(tmp_path)) == HOOK_STATE_MISSING
+
+
+def test_get_hook_state_current(tmp_path):
+    (tmp_path / HOOK_NAME).write_text(HOOK_SCRIPT)
+    assert get_hook_state(str(tmp_path)) == HOOK_STATE_CURRENT
+
+
+def test_get_hook_state_older_noidea_script_is_stale(tmp_path):
+    (tmp_path / HOOK_NAME).write_text('#!/bin/bash\nnoidea suggest --file "$1"\n')
+    assert get_hook_state(str(tmp_path)) == HOOK_STATE_STALE
+
+
+def test_get_hook_state_other_tool_is_foreign(tmp_path):
+    (tmp_path / HOOK_NAME).write_text("#!/bin/sh\nexec husky\n")
+    assert get_hook_state(str(tmp_path)) == HOOK_STATE_FOREIGN
@@ -0,0 +1,91 @@
+import pytest
+
+from noidea.message_check import SUBJECT_LENGTH_MAX, check_message, clean_message
+
+
+class TestCleanMessage:
+    def test_drops_comments_and_scissors_section(self):
+        text = (
+            "feat: add thing\n"
+            "# Please enter the commit message\n"
+            "# ------------------------ >8 ------------------------\n"
+            "diff --git a/x b/x\n"
+        )
+        assert clean_message(text) == "feat: add thing"
+
+    def test_rejects_non_string(self):
+        with pytest.raises(TypeError):
+            clean_message(None)
+
+
+class TestCheckMessage:
+    def test_conventional_message_passes(self):
+        assert check_message("feat(cli): add validate command\n\nWhy it matters.") == []
+
+    def test_breaking_change_marker_passes(self):
+        assert check_message("refactor(api)!: drop the v1 endpoints") == []
+
+    def test_empty_message(self):
+        assert check_message("# only a comment\n") == ["message is empty"]
+
+    def test_not_conventional(self):
+        assert check_message("Fixed the bug") == ["subject is not 'type(scope): description'"]
+
+    def test_unknown_type(self):
+        problems = check_message("feature: add thing")
+        assert len(problems) == 1
+        assert "unknown type 'feature'" in problems[0]
+
+    def test_long_subject_with_period(self):
+        subject = "fix: " + "x" * SUBJECT_LENGTH_MAX + "."
+        problems = check_message(subject)
+        assert f"subject is {len(subject)} characters (max {SUBJECT_LENGTH_MAX})" in problems
+        assert "subject ends with a period" in problems
+
+    def test_missing_blank_line(self):
+        assert check_message("fix: thing\nbody right away") == [
+            "no blank line between subject and body"
+        ]
+
+    def test_merge_and_fixup_commits_are_exempt(self):
+        assert check_message("Merge branch 'main' into feature") == []
+        assert check_message("fixup! feat: add thing") == []
+
+    def test_git_revert_subject_is_exempt(self):
+        message = 'Revert "feat: add thing"\n\nThis reverts commit ' + "a" * 40 + "."
+        assert check_message(message) == []
+
+    def test_message_matching_pattern_passes(self):
+        assert check_message("fix: handle empty diff\n\nRefs PROJ-12", pattern=r"[A-Z]+-\d+") == []
+
+    def test_message_missing_pattern_shows_pattern_and_example(self):
+        problems = check_message(
+            "fix: handle empty diff", pattern=r"[A-Z]+-\d+", pattern_example="fix: PROJ-12 x"
+        )
+        assert problems == [
+            r"message doesn't match the required pattern '[A-Z]+-\d+' (e.g. 'fix: PROJ-12 x')"
+        ]
+
+    def test_pattern_is_checked_alongside_the_format(self):
+        problems = check_message("Fixed the bug", pattern=r"[A-Z]+-\d+")
+        assert len(problems) == 2
+
+    def test_invalid_pattern_is_rejected(self):
+        with pytest.raises(ValueError, match="pattern"):
+            check_message("fix: thing", pattern="([A-Z]+")
+
+    def test_allowed_scope_passes(self):
+        assert check_message("fix(cli): handle empty diff", ["cli", "git"]) == []
+
+    def test_missing_scope_passes_with_allowlist(self):
+        assert check_message("chore: bump deps", ["cli", "git"]) == []
+
+    def test_disallowed_scope_suggests_closest(self):
+        assert check_message("fix(clii): handle empty diff", ["cli", "git"]) == [
+            "unknown scope 'clii' (did you mean 'cli'?)"
+        ]
+
+    def test_disallowed_scope_without_close_match_lists_scopes(self):
+        assert check_message("fix(docs): typo", ["cli", "git"]) == [
+            "unknown scope 'docs' (use one of cli, git)"
+        ]
@@ -0,0 +1,40 @@
+import pytest
+
+from noidea.policy import disabled_reason
+
+
+class TestBranchGlobs:
+    def test_glob_matches_branch(self):
+        reason = disabled_reason("security/cve-2024-1", [], ["security/*"], [])
+        assert reason == "branch 'security/cve-2024-1' matches llm.disable_on_branches 'security/*'"
+
+    def test_star_crosses_slashes(self):
+        assert disabled_reason("security/cve/fix", [], ["security/*"], []) is not None
+
+    def test_exact_branch_name(self):
+        assert disabled_reason("hotfix", [], ["hotfix"], []) is not None
+
+    def test_non_matching_branch(self):
+        assert disabled_reason("feature/security-page", [], ["security/*"], []) is None
+
+    def test_matching_is_case_sensitive(self):
+        assert disabled_reason("Security/fix", [], ["security/*"], []) is None
+
+    def test_detached_head_matches_nothing(self):
+        assert disabled_reason("", [], ["*"], []) is None
+
+
+class TestPathGlobs:
+    def test_any_staged_path_disables(self):
+        reason = disabled_reason("main", ["app.py", "secrets/prod.env"], [], ["secrets/*"])
+        assert reason == "'secrets/prod.env' matches llm.disable_on_paths 'secrets/*'"
+
+    def test_extension_glob(self):
+        assert disabled_reason("main", ["deploy/key.pem"], [], ["*.pem"]) is not None
+
+    def test_no_patterns_allows_everything(self):
+        assert disabled_reason("security/x", ["secrets/a"], [], []) is None
+
+    def test_rejects_non_string_branch(self):
+        with pytest.raises(TypeError):
+            disabled_reason(None, [], [], [])
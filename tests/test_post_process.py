@@ -0,0 +1,61 @@
+import shlex
+import sys
+
+import pytest
+
+from noidea.post_process import check_post_process_command, post_process
+
+
+def _python(code: str) -> str:
+    return f"{shlex.quote(sys.executable)} -c {shlex.quote(code)}"
+
+
+class TestPostProcess:
+    def test_stdout_replaces_message(self):
+        command = _python("import sys; print(sys.stdin.read().upper())")
+        result = post_process(command, "fix: handle empty diff")
+        assert result.text == "FIX: HANDLE EMPTY DIFF"
+        assert result.error == ""
+
+    def test_secrets_are_redacted_before_the_command_sees_them(self):
+        command = _python("import sys; print(sys.stdin.read())")
+        key = "sk-ant-" + "a1" * 20
+        result = post_process(command, f"fix: rotate {key}")
+        assert key not in result.text
+        assert "[REDACTED anthropic key]" in result.text
+
+    def test_failing_command_keeps_message(self):
+        command = _python("import sys; sys.exit('formatter broke')")
+        result = post_process(command, "fix: thing")
+        assert result.text == "fix: thing"
+        assert "formatter broke" in result.error
+
+    def test_missing_command_keeps_message(self):
+        result = post_process("noidea-no-such-formatter", "fix: thing")
+        assert result.text == "fix: thing"
+        assert result.error
+
+    def test_non_executable_command_keeps_message(self, tmp_path):
+        script = tmp_path / "formatter"
+        script.write_text("#!/bin/sh\ncat\n")
+        script.chmod(0o644)
+        result = post_process(str(script), "fix: thing")
+        assert result.text == "fix: thing"
+        assert "Permission denied" in result.error
+
+    def test_timeout_keeps_message(self):
+        command = _python("import time; time.sleep(5)")
+        result = post_process(command, "fix: thing", timeout_seconds=0.2)
+        assert result.text == "fix: thing"
+        assert result.error == "timed out after 0.2s"
+
+    def test_empty_output_keeps_message(self):
+        result = post_process(_python("pass"), "fix: thing")
+        assert result.text == "fix: thing"
+        assert result.error == "it printed nothing"
+
+    def test_unparseable_command(self):
+        assert check_post_process_command("fmt 'unclosed") is not None
+        assert check_post_process_command("   ") == "is empty"
+        with pytest.raises(ValueError, match="command"):
+            post_process("", "fix: thing")
@@ -0,0 +1,178 @@
+import pytest
+
+from noidea.trailers import (
+    append_trailers,
+    check_configured_trailer,
+    closing_references,
+    find_trailers,
+    format_sign_off,
+    generate_change_id,
+    is_trailer,
+    issue_number_from_branch,
+    issue_trailer_for_branch,
+    resolve_configured_trailers,
+)
+
+
+class TestFormatSignOff:
+    def test_formats_name_and_email(self):
+        assert format_sign_off("Ada Lovelace", "ada@example.com") == (
+            "Signed-off-by: Ada Lovelace <ada@example.com>"
+        )
+
+    def test_rejects_missing_email(self):
+        with pytest.raises(ValueError, match="email"):
+            format_sign_off("Ada Lovelace", "")
+
+
+class TestIsTrailer:
+    def test_accepts_key_value(self):
+        assert is_trailer("Reviewed-by: Someone <s@example.com>")
+
+    def test_rejects_free_text(self):
+        assert not is_trailer("just a sentence")
+
+    def test_rejects_missing_value(self):
+        assert not is_trailer("Refs:")
+
+
+class TestAppendTrailers:
+    SIGN_OFF = "Signed-off-by: Ada Lovelace <ada@example.com>"
+
+    def test_subject_only_gets_new_paragraph(self):
+        # "feat: x" looks like a trailer but is the subject, so a blank line must follow it.
+        result = append_trailers("feat: add thing", [self.SIGN_OFF])
+        assert result == f"feat: add thing\n\n{self.SIGN_OFF}"
+
+    def test_body_gets_new_paragraph(self):
+        result = append_trailers("feat: add thing\n\n- does stuff\n", [self.SIGN_OFF])
+        assert result == f"feat: add thing\n\n- does stuff\n\n{self.SIGN_OFF}"
+
+    def test_extends_existing_trailer_block(self):
+        message = "feat: add thing\n\nReviewed-by: Someone <s@example.com>"
+        result = append_trailers(message, [self.SIGN_OFF])
+        assert result == f"{message}\n{self.SIGN_OFF}"
+
+    def test_skips_trailer_already_present(self):
+        message = f"feat: add thing\n\n{self.SIGN_OFF}"
+        assert append_trailers(message, [self.SIGN_OFF, self.SIGN_OFF]) == message
+
+    def test_rejects_malformed_trailer(self):
+        with pytest.raises(ValueError, match="trailer"):
+            append_trailers("feat: add thing", ["not a trailer"])
+
+
+class TestFindTrailers:
+    def test_ignores_comment_lines(self):
+        text = "# Signed-off-by: Commented <c@example.com>\nSigned-off-by: Ada <ada@example.com>\n"
+        assert find_trailers(text, "Signed-off-by") == ["Signed-off-by: Ada <ada@example.com>"]
+
+    def test_ignores_indented_diff_context(self):
+        text = " Signed-off-by: Someone Else <x@example.com>\n+Change-Id: I" + "0" * 40 + "\n"
+        assert find_trailers(text, "Signed-off-by") == []
+        assert find_trailers(text, "Change-Id") == []
+
+
+class TestIssueTrailer:
+    def test_branch_prefixed_with_number(self):
+        assert issue_trailer_for_branch("refs", "42-foo") == "Refs #42"
+
+    def test_closes_mode(self):
+        assert issue_trailer_for_branch("closes", "feature/42-fix-login") == "Closes #42"
+
+    def test_issue_prefix(self):
+        assert issue_number_from_branch("issue-7") == 7
+
+    def test_no_number_in_branch(self):
+        assert issue_trailer_for_branch("refs", "main") is None
+        assert issue_number_from_branch("release-v2") is None
+
+    def test_off_mode(self):
+        assert issue_trailer_for_branch("off", "42-foo") is None
+
+    def test_unknown_mode(self):
+        with pytest.raises(ValueError, match="mode"):
+            issue_trailer_for_branch("fixes", "42-foo")
+
+    def test_configured_branch_pattern(self):
+        pattern = r"^JIRA-([0-9]+)"
+        assert issue_trailer_for_branch("refs", "JIRA-314-login", pattern) == "Refs #314"
+        assert issue_number_from_branch("42-foo", pattern) is None
+
+    def test_branch_pattern_with_skipped_group(self):
+        assert issue_number_from_branch("main", r"^(?:JIRA-([0-9]+))?") is None
+
+    def test_branch_pattern_needs_one_group(self):
+        with pytest.raises(ValueError, match="capture group"):
+            issue_number_from_branch("42-foo", r"[0-9]+")
+
+    def test_issue_reference_counts_as_trailer(self):
+        assert is_trailer("Refs #42")
+        assert append_trailers("feat: x\n\nRefs #42", ["Signed-off-by: A <a@b.c>"]) == (
+            "feat: x\n\nRefs #42\nSigned-off-by: A <a@b.c>"
+        )
+
+
+class TestClosingReferences:
+    def test_every_github_keyword(self):
+        for keyword in ("close", "closes", "closed", "fix", "fixes", "fixed"):
+            assert closing_references(f"feat: x\n\n{keyword} #3") == [3]
+        for keyword in ("resolve", "resolves", "resolved"):
+            assert closing_references(f"feat: x\n\n{keyword} #3") == [3]
+
+    def test_case_and_colon(self):
+        assert closing_references("fix: x\n\nFIXES: #8") == [8]
+
+    def test_several_issues_and_footers(self):
+        message = "feat: x\n\nCloses #1, #2 and #3\nFixes #2\nResolves #9"
+        assert closing_references(message) == [1, 2, 3, 9]
+
+    def test_plain_references_do_not_close(self):
+        assert closing_references("feat: x\n\nRefs #4\nSee #5, prefixes #6") == []
+
+    def test_keyword_without_number(self):
+        assert closing_references("fix: close the file handle") == []
+
+
+class TestChangeId:
+    def test_gerrit_format(self):
+        change_id = generate_change_id("feat: x")
+        assert len(change_id) == 41
+        assert change_id[0] == "I"
+        int(change_id[1:], 16)
+
+    def test_same_nonce_is_reproducible(self):
+        assert generate_change_id("feat: x", nonce="n") == generate_change_id("feat: x", nonce="n")
+
+    def test_new_id_on_every_call(self):
+        assert generate_change_id("feat: x") != generate_change_id("feat: x")
+
+
+class TestConfiguredTrailers:
+    def test_static_trailers_pass_through(self):
+        assert resolve_configured_trailers(["Reviewed-by: Bo <bo@x.io>"], "feat: x", []) == [
+            "Reviewed-by: Bo <bo@x.io>"
+        ]
+
+    def test_change_id_is_generated(self):
+        resolved = resolve_configured_trailers(["Change-Id: <generated>"], "feat: x", [])
+        assert len(resolved) == 1
+        assert resolved[0].startswith("Change-Id: I")
+        assert append_trailers("feat: x", resolved).endswith(resolved[0])
+
+    def test_existing_change_id_is_kept(self):
+        existing = ["Change-Id: I" + "a" * 40]
+        assert resolve_configured_trailers(["Change-Id: <generated>"], "feat: x", existing) == []
+
+    def test_change_id_in_message_is_kept(self):
+        message = "feat: x\n\nChange-Id: I" + "b" * 40
+        assert resolve_configured_trailers(["Change-Id: <generated>"], message, []) == []
+
+    def test_rejects_malformed_trailer(self):
+        assert "not a 'Key: value' trailer" in check_configured_trailer("Reviewed by Bo")
+        with pytest.raises(ValueError):
+            resolve_configured_trailers(["nope"], "feat: x", [])
+
+    def test_only_change_id_can_be_generated(self):
+        assert "only Change-Id" in check_configured_trailer("Reviewed-by: <generated>")
+        assert check_configured_trailer("Change-Id: <generated>") is None